@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// easeOutSine maps linear progress t in [0, 1] onto a curve that starts fast
+// and settles gently into 1.
+func easeOutSine(t float64) float64 {
+	return math.Sin(t * math.Pi / 2)
+}
+
+// revealFadeStagger is how many ticks each step away from the click waits
+// before fading in, when a cascade opens all at once.
+const revealFadeStagger = 1
+
+// startFade fades the just opened cell p in, starting after delay ticks.
+// Nothing fades with animations off.
+func (g *game) startFade(p [2]int, delay int) {
+	if !g.animationsEnabled {
+		return
+	}
+	if g.fading == nil {
+		g.fading = map[[2]int]int{}
+	}
+	// the count starts below zero and the fade begins when it reaches it
+	g.fading[p] = -delay
+}
+
+// toggleAnimations switches the reveal fade, explosions and confetti on or
+// off.
+func (g *game) toggleAnimations() {
+	g.animationsEnabled = !g.animationsEnabled
+	if !g.animationsEnabled {
+		g.fading = nil
+		g.particles = nil
+		g.shakeFrames = 0
+		g.confetti = nil
+	}
+	g.showToast("Animations: " + onOff(g.animationsEnabled))
+}
+
+// A detonation shakes the board for shakeDuration frames, by up to
+// shakeAmplitude pixels at first and less as it settles.
+const (
+	shakeDuration  = 12
+	shakeAmplitude = 4
+)
+
+// nextShake is the board's offset for this frame, counting the shake down.
+func (g *game) nextShake() point {
+	if g.shakeFrames <= 0 {
+		return point{}
+	}
+	a := (shakeAmplitude*g.shakeFrames + shakeDuration - 1) / shakeDuration
+	g.shakeFrames--
+	return point{X: rand.Intn(2*a+1) - a, Y: rand.Intn(2*a+1) - a}
+}
+
+// hintPulseStep is how far the hint pulse moves each frame.
+const hintPulseStep = 0.05
+
+// stepHintPulse advances the hint pulse while a hint is on the board.
+func (g *game) stepHintPulse() {
+	if g.hint == nil {
+		return
+	}
+	g.hintPulse += hintPulseStep
+	if g.hintPulse >= 1 {
+		g.hintPulse--
+	}
+}
+
+// hintPulseStroke is the hint outline's width, 1 to 3, and alpha, 128 to
+// 255, at the current point of the pulse.
+func (g *game) hintPulseStroke() (float32, uint8) {
+	k := math.Sin(float64(g.hintPulse) * math.Pi)
+	return float32(1 + 2*k), uint8(128 + 127*k)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEaseOutSine(t *testing.T) {
+	tests := []struct {
+		t, want float64
+	}{
+		{0, 0},
+		{0.5, math.Sqrt2 / 2},
+		{1, 1},
+	}
+	for _, tc := range tests {
+		if got := easeOutSine(tc.t); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("easeOutSine(%v) = %v, want %v", tc.t, got, tc.want)
+		}
+	}
+	// sine-out runs ahead of linear progress, then settles into 1
+	prev := 0.0
+	for i := 1; i < 100; i++ {
+		x := float64(i) / 100
+		got := easeOutSine(x)
+		if got <= prev || got < x {
+			t.Fatalf("easeOutSine(%v) = %v after %v", x, got, prev)
+		}
+		prev = got
+	}
+}
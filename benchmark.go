@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// runBenchmark lays out n boards of d, each opened at its centre, and plays
+// SolveStep on them until it gets stuck. It prints how fast that went, the
+// average 3BV and how much of each board the solver cleared on average.
+func runBenchmark(n int, d difficulty) {
+	start := time.Now()
+	var bv3, solved int
+	var cleared float64
+	for i := 0; i < n; i++ {
+		b := newBoard(d.W, d.H, d.Mines)
+		b.ConnectivityMode = d.Connectivity
+		b.placeMines(d.W/2, d.H/2)
+		bv3 += b.Compute3BV()
+		if hit, _ := b.reveal(b.start.X, b.start.Y); !hit && b.runSolver() {
+			solved++
+		}
+		cleared += float64(b.revealedCnt) / float64(b.W*b.H-b.Mines)
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("%d %s boards (%dx%d, %d mines)\n", n, d.Name, d.W, d.H, d.Mines)
+	fmt.Printf("total time:       %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("boards/sec:       %.1f\n", float64(n)/elapsed.Seconds())
+	fmt.Printf("average 3BV:      %.1f\n", float64(bv3)/float64(n))
+	fmt.Printf("solver cleared:   %.1f%% on average, %d/%d boards fully\n", cleared/float64(n)*100, solved, n)
+}
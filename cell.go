@@ -0,0 +1,92 @@
+package main
+
+// CellState is what the player sees of a cell. The states are exclusive, so
+// combinations such as a revealed flag cannot be represented.
+type CellState uint8
+
+const (
+	StateHidden CellState = iota
+	StateFlagged
+	StateQuestion
+	StateRevealed
+	// StateExploded is the revealed mine that ended the game.
+	StateExploded
+	// StateWrongFlag is a flag on a safe cell, exposed after a loss.
+	StateWrongFlag
+)
+
+type cell struct {
+	Mine     bool
+	Adjacent int
+	State    CellState
+	// Locked pins a hidden cell: it cannot be opened or marked until the
+	// lock is taken off again.
+	Locked bool `json:",omitempty"`
+}
+
+func (c *cell) Revealed() bool  { return c.State == StateRevealed || c.State == StateExploded }
+func (c *cell) Flagged() bool   { return c.State == StateFlagged || c.State == StateWrongFlag }
+func (c *cell) Question() bool  { return c.State == StateQuestion }
+func (c *cell) Exploded() bool  { return c.State == StateExploded }
+func (c *cell) WrongFlag() bool { return c.State == StateWrongFlag }
+
+// unmarked reports whether the cell is hidden with no flag, though it may
+// carry a question mark.
+func (c *cell) unmarked() bool { return c.State == StateHidden || c.State == StateQuestion }
+
+// The transitions below return false and leave the cell alone when the move
+// is not allowed from its current state.
+
+// Flag puts a flag on a hidden or question-marked cell.
+func (c *cell) Flag() bool {
+	if !c.unmarked() {
+		return false
+	}
+	c.State = StateFlagged
+	return true
+}
+
+// Unflag clears a flag or question mark.
+func (c *cell) Unflag() bool {
+	if c.State != StateFlagged && c.State != StateQuestion {
+		return false
+	}
+	c.State = StateHidden
+	return true
+}
+
+// Reveal opens a hidden or question-marked cell. Flags must be removed first.
+func (c *cell) Reveal() bool {
+	if !c.unmarked() {
+		return false
+	}
+	c.State = StateRevealed
+	return true
+}
+
+// MarkQuestion puts a question mark on a hidden or flagged cell.
+func (c *cell) MarkQuestion() bool {
+	if c.State != StateHidden && c.State != StateFlagged {
+		return false
+	}
+	c.State = StateQuestion
+	return true
+}
+
+// SetExploded reveals the cell as the mine that was hit.
+func (c *cell) SetExploded() bool {
+	if !c.unmarked() {
+		return false
+	}
+	c.State = StateExploded
+	return true
+}
+
+// SetWrongFlag marks a flag as misplaced once the game is lost.
+func (c *cell) SetWrongFlag() bool {
+	if c.State != StateFlagged {
+		return false
+	}
+	c.State = StateWrongFlag
+	return true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCellJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         cell
+		wantField map[string]bool // field name -> present
+	}{
+		{
+			name:      "hidden safe cell",
+			c:         cell{Adjacent: 2},
+			wantField: map[string]bool{"Mine": true, "Adjacent": true, "State": true, "Locked": false},
+		},
+		{
+			name:      "flagged mine",
+			c:         cell{Mine: true, Adjacent: 1, State: StateFlagged},
+			wantField: map[string]bool{"Mine": true, "Adjacent": true, "State": true, "Locked": false},
+		},
+		{
+			name:      "locked cell",
+			c:         cell{Adjacent: 3, Locked: true},
+			wantField: map[string]bool{"Mine": true, "Adjacent": true, "State": true, "Locked": true},
+		},
+		{
+			name:      "exploded mine",
+			c:         cell{Mine: true, State: StateExploded},
+			wantField: map[string]bool{"Mine": true, "Adjacent": true, "State": true, "Locked": false},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tc.wantField {
+				if _, ok := fields[name]; ok != want {
+					t.Fatalf("%s: field %s present=%v, want %v", data, name, ok, want)
+				}
+			}
+			if len(fields) > len(tc.wantField) {
+				t.Fatalf("%s: unexpected fields", data)
+			}
+			var back cell
+			if err := json.Unmarshal(data, &back); err != nil {
+				t.Fatal(err)
+			}
+			if back != tc.c {
+				t.Fatalf("round trip gave %+v, want %+v", back, tc.c)
+			}
+		})
+	}
+}
+
+func TestCellTransitions(t *testing.T) {
+	ops := []struct {
+		name string
+		do   func(c *cell) bool
+		// allowed maps each state the move is valid from to the result
+		allowed map[CellState]CellState
+	}{
+		{"Flag", (*cell).Flag, map[CellState]CellState{StateHidden: StateFlagged, StateQuestion: StateFlagged}},
+		{"Unflag", (*cell).Unflag, map[CellState]CellState{StateFlagged: StateHidden, StateQuestion: StateHidden}},
+		{"Reveal", (*cell).Reveal, map[CellState]CellState{StateHidden: StateRevealed, StateQuestion: StateRevealed}},
+		{"MarkQuestion", (*cell).MarkQuestion, map[CellState]CellState{StateHidden: StateQuestion, StateFlagged: StateQuestion}},
+		{"SetExploded", (*cell).SetExploded, map[CellState]CellState{StateHidden: StateExploded, StateQuestion: StateExploded}},
+		{"SetWrongFlag", (*cell).SetWrongFlag, map[CellState]CellState{StateFlagged: StateWrongFlag}},
+	}
+	states := []CellState{StateHidden, StateFlagged, StateQuestion, StateRevealed, StateExploded, StateWrongFlag}
+	for _, op := range ops {
+		for _, from := range states {
+			to, valid := op.allowed[from]
+			if !valid {
+				to = from
+			}
+			c := cell{Mine: true, Adjacent: 2, State: from}
+			if ok := op.do(&c); ok != valid {
+				t.Errorf("%s from %d reported %v, want %v", op.name, from, ok, valid)
+			}
+			if c.State != to {
+				t.Errorf("%s from %d left state %d, want %d", op.name, from, c.State, to)
+			}
+			if !c.Mine || c.Adjacent != 2 {
+				t.Errorf("%s from %d changed the layout: %+v", op.name, from, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build go1.21
+
+package main
+
+import "testing"
+
+// TestMinMaxBuiltin only compiles against the builtins: the legacy
+// stand-ins take ints alone, and no more than two of them.
+func TestMinMaxBuiltin(t *testing.T) {
+	if got := min(2.5, 1.5); got != 1.5 {
+		t.Errorf("min(2.5, 1.5) = %v, want 1.5", got)
+	}
+	if got := max(3, 9, 4); got != 9 {
+		t.Errorf("max(3, 9, 4) = %d, want 9", got)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		v, lo, hi, want int
+	}{
+		{5, 0, 10, 5},
+		{-3, 0, 10, 0},
+		{12, 0, 10, 10},
+		{0, 0, 10, 0},
+		{10, 0, 10, 10},
+		{7, 7, 7, 7},
+		{-20, -10, -5, -10},
+	}
+	for _, tc := range tests {
+		if got := clamp(tc.v, tc.lo, tc.hi); got != tc.want {
+			t.Errorf("clamp(%d, %d, %d) = %d, want %d", tc.v, tc.lo, tc.hi, got, tc.want)
+		}
+	}
+}
+
+func TestAbsInt(t *testing.T) {
+	tests := []struct {
+		v, want int
+	}{
+		{0, 0},
+		{4, 4},
+		{-4, 4},
+	}
+	for _, tc := range tests {
+		if got := absInt(tc.v); got != tc.want {
+			t.Errorf("absInt(%d) = %d, want %d", tc.v, got, tc.want)
+		}
+	}
+}
+
+// TestMinMax holds on either toolchain: the builtins from Go 1.21 on, the
+// stand-ins in minmax_legacy.go before it.
+func TestMinMax(t *testing.T) {
+	tests := []struct {
+		a, b, min, max int
+	}{
+		{1, 2, 1, 2},
+		{2, 1, 1, 2},
+		{-3, 3, -3, 3},
+		{5, 5, 5, 5},
+	}
+	for _, tc := range tests {
+		if got := min(tc.a, tc.b); got != tc.min {
+			t.Errorf("min(%d, %d) = %d, want %d", tc.a, tc.b, got, tc.min)
+		}
+		if got := max(tc.a, tc.b); got != tc.max {
+			t.Errorf("max(%d, %d) = %d, want %d", tc.a, tc.b, got, tc.max)
+		}
+	}
+}
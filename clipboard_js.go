@@ -0,0 +1,17 @@
+//go:build js
+
+package main
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+func copyToClipboard(s string) error {
+	clip := js.Global().Get("navigator").Get("clipboard")
+	if clip.IsUndefined() {
+		return errors.New("clipboard API not available")
+	}
+	clip.Call("writeText", s)
+	return nil
+}
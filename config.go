@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Touch gesture defaults; config.json and the command line can change them.
+const (
+	defaultTouchSlopPx = 10
+	defaultLongPressMs = 360
+)
+
+// touchMoveSlopPx is how far a touch may wander and still count as a tap
+// or long press; touchLongPressDur is how long a press flags instead of
+// revealing.
+var (
+	touchMoveSlopPx   = defaultTouchSlopPx
+	touchLongPressDur = defaultLongPressMs * time.Millisecond
+)
+
+// appConfig is config.json in the config directory. Its settings apply at
+// start, before the command-line flags, which win. Zero leaves a setting at
+// its default.
+type appConfig struct {
+	LongPressMs int `json:"long_press_ms,omitempty"`
+	TouchSlopPx int `json:"touch_slop_px,omitempty"`
+	// MarathonBoards is the length of a Ctrl+M marathon.
+	MarathonBoards int `json:"marathon_boards,omitempty"`
+}
+
+func configJSONPath() string {
+	return configFilePath("config.json")
+}
+
+// loadConfig reads config.json; a missing or unreadable file gives the zero
+// config.
+func loadConfig() appConfig {
+	var c appConfig
+	data, err := os.ReadFile(configJSONPath())
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		debugf("config.json: %v", err)
+		return appConfig{}
+	}
+	return c
+}
+
+// apply sets the package settings the config overrides.
+func (c appConfig) apply() {
+	if c.LongPressMs > 0 {
+		touchLongPressDur = time.Duration(c.LongPressMs) * time.Millisecond
+	}
+	if c.TouchSlopPx > 0 {
+		touchMoveSlopPx = c.TouchSlopPx
+	}
+	if c.MarathonBoards > 0 {
+		marathonBoards = c.MarathonBoards
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+)
+
+// ContextMenu is the popup a right click opens on cell (X,Y) when
+// useContextMenu is on.
+type ContextMenu struct {
+	X, Y        int
+	Items       []string
+	SelectedIdx int
+	// rects are where the items were last drawn, for clicks to hit.
+	rects []image.Rectangle
+}
+
+// contextMenuItems are the actions the menu offers, in order.
+var contextMenuItems = []string{"Reveal", "Flag", "Lock", "Hint here"}
+
+const (
+	contextMenuItemH = 18
+	contextMenuW     = 90
+)
+
+// openContextMenu opens the menu on the cell under a right click. It
+// reports false when the click is not on the board, so the click is left
+// for the usual handling.
+func (g *game) openContextMenu(mx, my int) bool {
+	nx, ny := g.normalizeInputPos(mx, my)
+	if g.paused || g.state != statePlaying || g.showHelp || g.showScores {
+		return false
+	}
+	x, y, ok := g.boardPosFromCursor(nx, ny)
+	if !ok {
+		return false
+	}
+	g.contextMenu = &ContextMenu{X: x, Y: y, Items: contextMenuItems}
+	return true
+}
+
+// handleContextMenu runs the open menu: hovering or Up/Down picks an item,
+// a click or Enter runs it, and a click anywhere else or Esc dismisses it.
+func (g *game) handleContextMenu() {
+	m := g.contextMenu
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || g.state != statePlaying {
+		g.contextMenu = nil
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+		m.SelectedIdx = (m.SelectedIdx + 1) % len(m.Items)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+		m.SelectedIdx = (m.SelectedIdx + len(m.Items) - 1) % len(m.Items)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		g.runContextMenu(m.SelectedIdx)
+		return
+	}
+
+	mx, my := g.normalizeInputPos(ebiten.CursorPosition())
+	hovered := -1
+	for i, r := range m.rects {
+		if pointInRect(mx, my, r) {
+			hovered = i
+			m.SelectedIdx = i
+		}
+	}
+	primary, secondary := g.mouseButtons()
+	if inpututil.IsMouseButtonJustPressed(primary) {
+		if hovered >= 0 {
+			g.runContextMenu(hovered)
+		} else {
+			g.contextMenu = nil
+		}
+		return
+	}
+	if inpututil.IsMouseButtonJustPressed(secondary) && hovered < 0 {
+		g.contextMenu = nil
+	}
+}
+
+// runContextMenu closes the menu and does item i on its cell, counted as
+// the click it stands for.
+func (g *game) runContextMenu(i int) {
+	x, y := g.contextMenu.X, g.contextMenu.Y
+	g.contextMenu = nil
+	switch contextMenuItems[i] {
+	case "Reveal":
+		if g.b.cells[y][x].Revealed() {
+			g.chordClicks++
+		} else {
+			g.leftClicks++
+		}
+		g.revealCell(x, y)
+	case "Flag":
+		if !g.noFlags {
+			g.rightClicks++
+			g.markCell(x, y)
+		}
+	case "Lock":
+		g.lockCell(x, y)
+	case "Hint here":
+		g.hintAt(x, y)
+	}
+}
+
+// hintAt tells what can be said about one hidden cell: safe or a mine if
+// the solver can deduce it, otherwise its chance of being a mine. It costs
+// a hint like H.
+func (g *game) hintAt(x, y int) {
+	switch {
+	case g.NoGuess:
+		g.showToast("No hints in no-guess mode")
+		return
+	case !g.b.placed || g.b.cells[y][x].Revealed():
+		return
+	case !g.hintAvailable():
+		return
+	}
+	g.clearHints()
+	p := point{X: x, Y: y}
+	revealed, flagged, _ := g.b.SolveStep()
+	switch {
+	case containsCell(revealed, x, y):
+		g.hint = &p
+		g.hintReason = "Deduced safe"
+	case containsCell(flagged, x, y):
+		g.antiHint = &p
+		g.hintReason = "Deduced mine"
+	default:
+		g.hintReason = fmt.Sprintf("Risk here %.0f%%", g.b.MineHeatmap()[y][x]*100)
+	}
+	g.spendHint()
+	g.logEvent("hint", x, y, "here")
+	g.events.Publish(HintUsedEvent{Reason: g.hintReason})
+}
+
+func containsCell(cells [][2]int, x, y int) bool {
+	for _, c := range cells {
+		if c[0] == x && c[1] == y {
+			return true
+		}
+	}
+	return false
+}
+
+// drawContextMenu draws the open menu beside its cell, kept on screen.
+func (g *game) drawContextMenu(screen *ebiten.Image, th theme) {
+	m := g.contextMenu
+	px, py := g.cellOrigin(m.X, m.Y)
+	span := int(g.cellSpan())
+	w, h := contextMenuW, len(m.Items)*contextMenuItemH+4
+	sw, sh := screen.Bounds().Dx(), screen.Bounds().Dy()
+	x, y := px+span, py
+	if x+w > sw {
+		x = px - w
+	}
+	x, y = clamp(x, 0, max(sw-w, 0)), clamp(y, 0, max(sh-h, 0))
+	drawRaisedRect(screen, x, y, w, h, th)
+	m.rects = m.rects[:0]
+	for i, item := range m.Items {
+		r := image.Rect(x+2, y+2+i*contextMenuItemH, x+w-2, y+2+(i+1)*contextMenuItemH)
+		m.rects = append(m.rects, r)
+		clr := th.HeaderText
+		if i == m.SelectedIdx {
+			ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), th.Accent)
+			clr = th.Panel
+		}
+		text.Draw(screen, item, basicfont.Face7x13, r.Min.X+6, r.Min.Y+13, clr)
+	}
+}
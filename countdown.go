@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// countdownPrefix starts the score keys of countdown games.
+const countdownPrefix = "countdown"
+
+// countdownLimits are the limits Ctrl+T cycles through; 0 is off.
+var countdownLimits = []int{0, 60, 120, 300}
+
+// cycleCountdown moves to the next countdown limit and starts a new game
+// under it.
+func (g *game) cycleCountdown() {
+	g.stepCountdown(1)
+}
+
+// stepCountdown moves dir places through countdownLimits, wrapping, and
+// starts a new game under the new limit.
+func (g *game) stepCountdown(dir int) {
+	next := 0
+	n := len(countdownLimits)
+	for i, s := range countdownLimits {
+		if s == g.CountdownSec {
+			next = countdownLimits[((i+dir)%n+n)%n]
+		}
+	}
+	g.CountdownSec = next
+	g.reset(false)
+	if next == 0 {
+		g.showToast("Countdown off")
+	} else {
+		g.showToast(fmt.Sprintf("Countdown %ds", next))
+	}
+}
+
+// countdownLeft is the time left on the countdown.
+func (g *game) countdownLeft() time.Duration {
+	return max(time.Duration(g.CountdownSec)*time.Second-g.elapsed, 0)
+}
+
+// checkCountdown ends a countdown game whose time has run out.
+func (g *game) checkCountdown() {
+	if g.CountdownSec == 0 || g.state != statePlaying || g.timerStart.IsZero() || g.countdownLeft() > 0 {
+		return
+	}
+	g.revealQueue = nil
+	g.onGameLost()
+	g.showToast("Time's up!")
+}
+
+// countdownUrgent reports whether fewer than ten seconds remain, which
+// flashes the mine counter.
+func (g *game) countdownUrgent() bool {
+	return g.CountdownSec > 0 && g.state == statePlaying && !g.timerStart.IsZero() && g.countdownLeft() < 10*time.Second
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// dailyPrefix starts the score and stats keys of daily challenges so they
+// never mix with regular Expert records.
+const dailyPrefix = "daily"
+
+// dailySeed turns a calendar day into the layout seed everyone shares.
+func dailySeed(t time.Time) int64 {
+	y, m, d := t.Date()
+	return int64(y*10000 + int(m)*100 + d)
+}
+
+// dailyDifficulty is Expert, tagged with the day it belongs to.
+func dailyDifficulty(t time.Time) difficulty {
+	d := presets[2]
+	d.Daily = t.Format("2006-01-02")
+	return d
+}
+
+// startDaily switches to today's challenge, or shows how it went if it has
+// already been played today.
+func (g *game) startDaily() {
+	now := time.Now()
+	d := dailyDifficulty(now)
+	st := g.stats[g.dailyKey()]
+	if st != nil && st.DailyDate == d.Daily {
+		switch {
+		case st.DailyWon:
+			g.showToast(fmt.Sprintf("Daily %s: won in %s", d.Daily, formatDuration(time.Duration(st.DailyTimeMs)*time.Millisecond)))
+		default:
+			g.showToast(fmt.Sprintf("Daily %s: already played", d.Daily))
+		}
+		return
+	}
+	g.setDifficulty(d)
+	g.b.SetSeed(dailySeed(now))
+}
+
+// dailyKey is the score key of the daily challenge with the current modes.
+func (g *game) dailyKey() string {
+	d := g.diff
+	g.diff = dailyDifficulty(time.Now())
+	key := g.scoreKey()
+	g.diff = d
+	return key
+}
+
+// recordDailyAttempt uses up today's attempt. It runs on the first reveal,
+// so leaving a daily game half played still counts.
+func (g *game) recordDailyAttempt() {
+	st := g.statsFor(g.scoreKey())
+	st.DailyDate = g.diff.Daily
+	st.DailyWon = false
+	st.DailyTimeMs = 0
+	saveStats(g.stats)
+}
@@ -0,0 +1,71 @@
+//go:build debug
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Built with -tags debug: board operations verify their invariants and
+// fail loudly on the first inconsistency, and Alt+click inspects a cell.
+
+func (b *board) debugCheck(op string) {
+	if err := b.Validate(); err != nil {
+		panic(fmt.Sprintf("board inconsistent after %s: %v", op, err))
+	}
+}
+
+// debugf logs a line to stderr.
+func debugf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// inspectCellAt dumps the cell under the cursor to stderr as JSON and keeps
+// it for drawDebugPanel. It reports whether the click hit the board.
+func (g *game) inspectCellAt(mx, my int) bool {
+	mx, my = g.normalizeInputPos(mx, my)
+	x, y, ok := g.boardPosFromCursor(mx, my)
+	if !ok {
+		return false
+	}
+	g.inspected = &point{X: x, Y: y}
+	data, err := json.Marshal(g.b.cells[y][x])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cell (%d,%d): %v\n", x, y, err)
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "cell (%d,%d): %s\n", x, y, data)
+	return true
+}
+
+// drawDebugPanel shows the inspected cell and the board counters in a
+// panel whose bottom-left corner is (x, y).
+func (g *game) drawDebugPanel(screen *ebiten.Image, x, y int, th theme) {
+	lines := []string{fmt.Sprintf("revealed %d  flags %d", g.b.revealedCnt, g.b.flagsCnt)}
+	if g.hint != nil {
+		lines = append(lines, fmt.Sprintf("hint (%d,%d) %s", g.hint.X, g.hint.Y, g.hintReason))
+	}
+	if p := g.inspected; p != nil && g.b.in(p.X, p.Y) {
+		c := g.b.cells[p.Y][p.X]
+		lines = append(lines,
+			fmt.Sprintf("cell (%d,%d) adj %d", p.X, p.Y, c.Adjacent),
+			fmt.Sprintf("mine %v revealed %v flagged %v", c.Mine, c.Revealed(), c.Flagged()),
+			fmt.Sprintf("question %v exploded %v wrong %v", c.Question(), c.Exploded(), c.WrongFlag()),
+		)
+	}
+	w := 0
+	for _, ln := range lines {
+		w = max(w, text.BoundString(g.fontMain, ln).Dx())
+	}
+	h := 6 + len(lines)*14
+	ebitenutil.DrawRect(screen, float64(x), float64(y-h), float64(w+8), float64(h), withAlpha(th.Panel, 220))
+	for i, ln := range lines {
+		text.Draw(screen, ln, g.fontMain, x+4, y-h+14+i*14, th.HeaderText)
+	}
+}
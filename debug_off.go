@@ -0,0 +1,13 @@
+//go:build !debug
+
+package main
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+func (b *board) debugCheck(string) {}
+
+func debugf(string, ...any) {}
+
+func (g *game) inspectCellAt(int, int) bool { return false }
+
+func (g *game) drawDebugPanel(*ebiten.Image, int, int, theme) {}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// toggleEditor opens the board editor on an empty board of the current
+// size, or closes it and goes back to the game it replaced. A game in
+// progress is thrown away.
+func (g *game) toggleEditor() {
+	if g.editorMode {
+		g.closeEditor()
+		return
+	}
+	if g.b.placed && g.state == statePlaying && g.b.revealedCnt > 0 {
+		g.showToast("Game in progress discarded for the editor")
+	}
+	g.reset(false)
+	g.editorPrev = g.b
+	g.b = newBoard(g.diff.W, g.diff.H, 1)
+	g.b.ConnectivityMode = g.diff.Connectivity
+	g.b.Mines = 0
+	g.editorMode = true
+	g.showHelp = false
+	g.showScores = false
+}
+
+// closeEditor leaves the editor without playing its layout.
+func (g *game) closeEditor() {
+	g.editorMode = false
+	g.b = g.editorPrev
+	g.editorPrev = nil
+}
+
+// handleEditor runs the board editor: a click toggles a mine, a right
+// click clears one, Enter plays the layout and Esc or Ctrl+E cancels.
+func (g *game) handleEditor() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || ctrlKeyJustPressed(ebiten.KeyE) {
+		g.closeEditor()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		g.finishEditor()
+		return
+	}
+	g.handleScrollKeys()
+	primary, secondary := g.mouseButtons()
+	x, y, ok := g.boardPosFromCursor(g.normalizeInputPos(ebiten.CursorPosition()))
+	if !ok {
+		return
+	}
+	if inpututil.IsMouseButtonJustPressed(primary) {
+		g.editMine(x, y, !g.b.cells[y][x].Mine)
+	}
+	if inpututil.IsMouseButtonJustPressed(secondary) {
+		g.editMine(x, y, false)
+	}
+}
+
+// editMine puts a mine on (x,y) or takes it off. A mine that would leave
+// every safe cell touching a mine is refused: such a layout has no opening
+// to start from.
+func (g *game) editMine(x, y int, mine bool) {
+	c := &g.b.cells[y][x]
+	if c.Mine == mine {
+		return
+	}
+	c.Mine = mine
+	if mine && !g.b.hasOpening() {
+		c.Mine = false
+		g.showToast("Leave at least one cell with no mine around it")
+		return
+	}
+	if mine {
+		g.b.Mines++
+	} else {
+		g.b.Mines--
+	}
+}
+
+// finishEditor starts playing the edited layout, as a puzzle so that
+// starting over keeps it.
+func (g *game) finishEditor() {
+	b := g.b
+	if b.Mines == 0 {
+		g.showToast("Place at least one mine")
+		return
+	}
+	b.computeAdjacent()
+	for y := 0; y < b.H && !b.placed; y++ {
+		for x := 0; x < b.W; x++ {
+			if c := b.cells[y][x]; !c.Mine && c.Adjacent == 0 {
+				b.start = point{X: x, Y: y}
+				b.placed = true
+				break
+			}
+		}
+	}
+	b.SetSeed(0)
+	g.editorMode = false
+	g.editorPrev = nil
+	g.loadPuzzle(b, "Editor")
+}
+
+// hasOpening reports whether some safe cell has no mine around it.
+func (b *board) hasOpening() bool {
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if b.cells[y][x].Mine {
+				continue
+			}
+			open := true
+			b.around(x, y, func(nx, ny int) {
+				if b.cells[ny][nx].Mine {
+					open = false
+				}
+			})
+			if open {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// drawEditorCell draws a cell of the editor board opened, with its mine if
+// it has one.
+func (g *game) drawEditorCell(screen *ebiten.Image, x, y int, th theme) {
+	px, py := g.cellOrigin(x, y)
+	cs, fs := float64(cellSizePx), float32(cellSizePx)
+	ebitenutil.DrawRect(screen, float64(px), float64(py), cs, cs, th.CellRevealed)
+	vector.StrokeRect(screen, float32(px), float32(py), fs, fs, 1, th.CellGrid, false)
+	if g.b.cells[y][x].Mine {
+		vector.DrawFilledCircle(screen, float32(px+cellSizePx/2), float32(py+cellSizePx/2), fs/4, th.Mine, false)
+	}
+}
+
+func (g *game) editorStatus() []string {
+	return []string{
+		fmt.Sprintf("EDITOR  %d mines", g.b.Mines),
+		"Click: toggle mine  Right-click: clear  Enter: play  Esc: cancel",
+	}
+}
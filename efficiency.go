@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// toggleEfficiency switches efficiency mode, where the score is the number
+// of clicks rather than the time, and starts a new game under it.
+func (g *game) toggleEfficiency() {
+	g.efficiencyMode = !g.efficiencyMode
+	g.reset(false)
+	if g.efficiencyMode {
+		g.showToast("Efficiency mode: fewest clicks wins")
+	} else {
+		g.showToast("Efficiency mode off")
+	}
+}
+
+// clickCount is every click of the current game: reveals, marks and chords.
+func (g *game) clickCount() int {
+	return g.leftClicks + g.rightClicks + g.chordClicks
+}
+
+// recordEfficiency keeps the win as the record for key when it took fewer
+// clicks, or as many clicks in less time. It reports whether it did.
+func (g *game) recordEfficiency(key string, elapsed time.Duration) bool {
+	clicks := max(g.clickCount(), 1)
+	best, ok := g.bestScores[key]
+	if ok && best.Clicks > 0 && (best.Clicks < clicks || best.Clicks == clicks && best.Duration() <= elapsed) {
+		return false
+	}
+	g.bestScores[key] = ScoreEntry{
+		DurationNs: int64(elapsed),
+		Seed:       g.b.seed,
+		Clicks:     clicks,
+		Mark:       g.scoreMark(),
+	}
+	return true
+}
+
+// drawClickBadge shows the click count in a small tag on the corner of the
+// face button.
+func (g *game) drawClickBadge(screen *ebiten.Image, faceX, faceY, faceSize int, th theme) {
+	s := strconv.Itoa(g.clickCount())
+	w := len(s)*7 + 4
+	x, y := faceX+faceSize-w/2, faceY-6
+	ebitenutil.DrawRect(screen, float64(x), float64(y), float64(w), 14, rgb(200, 30, 30))
+	drawTextCentered(screen, s, g.fontMain, x, y, w, rgb(255, 255, 255))
+}
+
+// efficiencyLine formats an efficiency record for the scores overlay.
+func efficiencyLine(k string, e ScoreEntry) string {
+	return fmt.Sprintf("%s : %d clicks (%s)%s", k, e.Clicks, formatDuration(e.Duration()), e.Mark)
+}
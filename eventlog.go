@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// logDir is where finished games write their event logs; empty means logs/
+// in the config directory.
+var logDir string
+
+// GameEvent is one player action in the event log. Unlike a ReplayEvent it
+// also keeps moves that changed nothing, and says how each one went.
+type GameEvent struct {
+	Time time.Duration `json:"time_ns"`
+	// Type is "reveal", "flag", "chord" or "hint".
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	// Result is "mine" when the move hit a mine (or a hint pointed at
+	// one), "noop" when it changed nothing and "safe" otherwise.
+	Result string `json:"result"`
+}
+
+// gameLog is the file written for one game.
+type gameLog struct {
+	Seed       int64       `json:"seed"`
+	Difficulty string      `json:"difficulty"`
+	ScoreKey   string      `json:"score_key"`
+	Won        bool        `json:"won"`
+	ElapsedNs  int64       `json:"elapsed_ns"`
+	Events     []GameEvent `json:"events"`
+}
+
+// logEvent appends an action to the event log. A playback is not logged.
+func (g *game) logEvent(typ string, x, y int, result string) {
+	if g.playback != nil {
+		return
+	}
+	var t time.Duration
+	if !g.timerStart.IsZero() {
+		t = time.Since(g.timerStart)
+	}
+	g.eventLog = append(g.eventLog, GameEvent{Time: t, Type: typ, X: x, Y: y, Result: result})
+}
+
+// moveResult is the Result of a reveal, chord or mark.
+func moveResult(hit, changed bool) string {
+	switch {
+	case hit:
+		return "mine"
+	case changed:
+		return "safe"
+	}
+	return "noop"
+}
+
+// writeEventLog writes the finished game's log to the log directory.
+func (g *game) writeEventLog(won bool) error {
+	if g.playback != nil || len(g.eventLog) == 0 {
+		return nil
+	}
+	dir := logDir
+	if dir == "" {
+		dir = configFilePath("logs")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(gameLog{
+		Seed:       g.b.seed,
+		Difficulty: g.diff.Name,
+		ScoreKey:   g.scoreKey(),
+		Won:        won,
+		ElapsedNs:  int64(g.elapsed),
+		Events:     g.eventLog,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405.000") + ".json"
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
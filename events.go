@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is anything published on the EventBus; the concrete types below are
+// the ones the game emits.
+type Event interface{}
+
+type GameStartedEvent struct{}
+
+type CellRevealedEvent struct {
+	X, Y     int
+	Adjacent int
+}
+
+type MineHitEvent struct {
+	X, Y int
+}
+
+type FlagPlacedEvent struct {
+	X, Y int
+}
+
+type GameWonEvent struct {
+	Elapsed time.Duration
+}
+
+type GameLostEvent struct {
+	Elapsed time.Duration
+}
+
+type HintUsedEvent struct {
+	Reason string
+}
+
+type ChordUsedEvent struct {
+	X, Y int
+	// Opened is false for a chord that did nothing.
+	Opened bool
+}
+
+type DifficultyChangedEvent struct {
+	Name string
+}
+
+// MoveEvent is a move as a replay records it.
+type MoveEvent struct {
+	Action ReplayAction
+	X, Y   int
+}
+
+const (
+	EventGameStarted  = "game_started"
+	EventCellRevealed = "cell_revealed"
+	EventMineHit      = "mine_hit"
+	EventFlagPlaced   = "flag_placed"
+	EventGameWon      = "game_won"
+	EventGameLost     = "game_lost"
+	EventHintUsed     = "hint_used"
+	EventChordUsed    = "chord_used"
+	EventDifficulty   = "difficulty_changed"
+	EventMove         = "move"
+)
+
+func eventName(e Event) string {
+	switch e.(type) {
+	case GameStartedEvent:
+		return EventGameStarted
+	case CellRevealedEvent:
+		return EventCellRevealed
+	case MineHitEvent:
+		return EventMineHit
+	case FlagPlacedEvent:
+		return EventFlagPlaced
+	case GameWonEvent:
+		return EventGameWon
+	case GameLostEvent:
+		return EventGameLost
+	case HintUsedEvent:
+		return EventHintUsed
+	case ChordUsedEvent:
+		return EventChordUsed
+	case DifficultyChangedEvent:
+		return EventDifficulty
+	case MoveEvent:
+		return EventMove
+	}
+	return ""
+}
+
+// EventBus lets subsystems such as sound, effects and recorders react to
+// game events without the game knowing about them. Handlers run
+// synchronously on the publishing goroutine.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(Event)
+}
+
+func (b *EventBus) Subscribe(event string, handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = map[string][]func(Event){}
+	}
+	b.handlers[event] = append(b.handlers[event], handler)
+}
+
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	hs := b.handlers[eventName(e)]
+	b.mu.RUnlock()
+	for _, h := range hs {
+		h(e)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEventBusPublish(t *testing.T) {
+	subscribed := []string{EventMineHit, EventGameWon, EventMineHit, EventFlagPlaced}
+	tests := []struct {
+		name  string
+		event Event
+		want  []int // indexes into subscribed
+	}{
+		{"every handler for the event", MineHitEvent{X: 1, Y: 2}, []int{0, 2}},
+		{"a single handler", GameWonEvent{}, []int{1}},
+		{"no handler", GameLostEvent{}, nil},
+		{"unknown event", struct{}{}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var bus EventBus
+			calls := make([]int, len(subscribed))
+			for i, name := range subscribed {
+				i := i
+				bus.Subscribe(name, func(e Event) {
+					if e != tc.event {
+						t.Errorf("handler %d got %v, want %v", i, e, tc.event)
+					}
+					calls[i]++
+				})
+			}
+			bus.Publish(tc.event)
+			want := make([]int, len(subscribed))
+			for _, i := range tc.want {
+				want[i] = 1
+			}
+			for i := range calls {
+				if calls[i] != want[i] {
+					t.Fatalf("handler %d called %d times, want %d", i, calls[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEventBusConcurrent(t *testing.T) {
+	const workers, rounds = 8, 100
+	var bus EventBus
+	var calls atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				bus.Subscribe(EventCellRevealed, func(Event) { calls.Add(1) })
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				bus.Publish(CellRevealedEvent{X: i})
+			}
+		}()
+	}
+	wg.Wait()
+
+	// with every subscription in, one publish reaches all of them
+	calls.Store(0)
+	bus.Publish(CellRevealedEvent{})
+	if got := calls.Load(); got != workers*rounds {
+		t.Fatalf("publish reached %d handlers, want %d", got, workers*rounds)
+	}
+}
+
+func TestGameSubscribers(t *testing.T) {
+	tests := []struct {
+		name      string
+		row       string
+		x         int
+		wantMoves int
+		wantShake bool
+	}{
+		{"a safe reveal is recorded", "*..", 2, 1, false},
+		{"a mine hit shakes the board", "*..", 0, 1, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t, tc.row)
+			g.animationsEnabled = true
+			g.revealCell(tc.x, 0)
+			if got := len(g.replayEvents); got != tc.wantMoves {
+				t.Fatalf("recorded %d moves, want %d", got, tc.wantMoves)
+			}
+			if got := g.shakeFrames > 0; got != tc.wantShake {
+				t.Fatalf("shaking = %v, want %v", got, tc.wantShake)
+			}
+		})
+	}
+}
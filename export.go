@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// tikzNumberColors mirrors the Classic theme's NumberColors using colour
+// names that ship with tikz (via xcolor), so the output needs no extra
+// packages.
+var tikzNumberColors = []string{
+	"",
+	"blue",
+	"green!50!black",
+	"red",
+	"blue!50!black",
+	"red!50!black",
+	"teal",
+	"black",
+	"gray",
+}
+
+// ToTikZ renders the board as a tikzpicture environment with one unit per
+// cell. Hidden mines are drawn only when showMines is set. The output depends
+// on the board alone, so the same board always yields the same text.
+func (b *board) ToTikZ(showMines bool) string {
+	var sb strings.Builder
+	sb.WriteString("\\begin{tikzpicture}[x=0.5cm,y=-0.5cm]\n")
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			fill := "gray!45"
+			switch {
+			case c.Exploded():
+				fill = "red!70"
+			case c.Revealed():
+				fill = "gray!15"
+			}
+			fmt.Fprintf(&sb, "\\draw[fill=%s] (%d,%d) rectangle (%d,%d);\n", fill, x, y, x+1, y+1)
+
+			cx, cy := float64(x)+0.5, float64(y)+0.5
+			switch {
+			case c.Mine && (c.Revealed() || showMines) && !c.Flagged():
+				fmt.Fprintf(&sb, "\\fill[black] (%.2f,%.2f) circle (0.28);\n", cx, cy)
+			case c.Flagged():
+				fmt.Fprintf(&sb, "\\fill[red] (%.2f,%.2f) -- (%.2f,%.2f) -- (%.2f,%.2f) -- cycle;\n",
+					cx-0.2, cy-0.3, cx+0.25, cy-0.1, cx-0.2, cy+0.1)
+				fmt.Fprintf(&sb, "\\draw[thick] (%.2f,%.2f) -- (%.2f,%.2f);\n", cx-0.2, cy-0.3, cx-0.2, cy+0.3)
+			case c.Revealed() && c.Adjacent > 0:
+				fmt.Fprintf(&sb, "\\node[text=%s,font=\\bfseries] at (%.2f,%.2f) {%d};\n",
+					tikzNumberColors[c.Adjacent], cx, cy, c.Adjacent)
+			case c.Question():
+				fmt.Fprintf(&sb, "\\node at (%.2f,%.2f) {?};\n", cx, cy)
+			}
+		}
+	}
+	sb.WriteString("\\end{tikzpicture}\n")
+	return sb.String()
+}
+
+// defaultSVGCellSize is the size of a cell in an SVG export, in SVG units;
+// --svg-cell-size changes it.
+const defaultSVGCellSize = 24
+
+var svgCellSize = defaultSVGCellSize
+
+// ExportSVG writes the board as a standalone SVG image in the colours of
+// th, cell units per cell. Like ToTikZ, hidden mines are drawn only when
+// showMines is set, and the output depends on its arguments alone.
+func (b *board) ExportSVG(w io.Writer, th theme, cell int, showMines bool) error {
+	var sb strings.Builder
+	fw, fh := b.W*cell, b.H*cell
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", fw, fh, fw, fh)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="%s"/>`+"\n", fw, fh, hexColor(th.BG))
+	// drawFlag's shapes are laid out on a 24-unit cell
+	s := float64(cell) / 24
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			px, py := x*cell, y*cell
+			fill, stroke := th.CellHidden, th.Dark
+			switch {
+			case c.Exploded():
+				fill, stroke = rgb(210, 40, 40), th.CellGrid
+			case c.Revealed():
+				fill, stroke = th.CellRevealed, th.CellGrid
+			}
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="%s"/>`+"\n",
+				px, py, cell, cell, hexColor(fill), hexColor(stroke))
+
+			fx, fy := float64(px), float64(py)
+			switch {
+			case c.Mine && (c.Revealed() || showMines) && !c.Flagged():
+				fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n",
+					fx+float64(cell)/2, fy+float64(cell)/2, float64(cell)/4, hexColor(th.Mine))
+			case c.Flagged():
+				fmt.Fprintf(&sb, `<path d="M%.1f %.1fh%.1fv%.1fh%.1fz M%.1f %.1fh%.1fv%.1fh%.1fz" fill="%s"/>`+"\n",
+					fx+11*s, fy+6*s, 2*s, 12*s, -2*s, fx+7*s, fy+17*s, 9*s, 2*s, -9*s, hexColor(th.CellText))
+				fmt.Fprintf(&sb, `<path d="M%.1f %.1fL%.1f %.1fL%.1f %.1fz" fill="%s"/>`+"\n",
+					fx+11*s, fy+6*s, fx+5*s, fy+10*s, fx+11*s, fy+14*s, hexColor(th.Flag))
+				if c.WrongFlag() {
+					fmt.Fprintf(&sb, `<path d="M%.1f %.1fL%.1f %.1fM%.1f %.1fL%.1f %.1f" stroke="%s" stroke-width="2"/>`+"\n",
+						fx+4*s, fy+4*s, fx+20*s, fy+20*s, fx+20*s, fy+4*s, fx+4*s, fy+20*s, hexColor(th.WrongFlag))
+				}
+			case c.Revealed() && c.Adjacent > 0:
+				svgText(&sb, fx, fy, cell, fmt.Sprint(c.Adjacent), th.NumberColors[c.Adjacent])
+			case c.Question():
+				svgText(&sb, fx, fy, cell, "?", th.CellText)
+			}
+		}
+	}
+	sb.WriteString("</svg>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// svgText centres s in the cell at (x, y).
+func svgText(sb *strings.Builder, x, y float64, cell int, s string, clr color.Color) {
+	fmt.Fprintf(sb, `<text x="%.1f" y="%.1f" fill="%s" font-family="monospace" font-weight="bold" font-size="%d" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n",
+		x+float64(cell)/2, y+float64(cell)/2, hexColor(clr), cell*2/3, s)
+}
+
+// ExportASCII renders the board one row per line: '.' hidden, 'F' flagged,
+// '?' question mark, a digit or ' ' for an opened cell, '*' an opened mine
+// and 'X' the exploded one. Hidden mines are not shown.
+func (b *board) ExportASCII() string {
+	var sb strings.Builder
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			switch {
+			case c.Exploded():
+				sb.WriteByte('X')
+			case c.Flagged():
+				sb.WriteByte('F')
+			case c.Question():
+				sb.WriteByte('?')
+			case !c.Revealed():
+				sb.WriteByte('.')
+			case c.Mine:
+				sb.WriteByte('*')
+			case c.Adjacent == 0:
+				sb.WriteByte(' ')
+			default:
+				sb.WriteByte(byte('0' + c.Adjacent))
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ImportASCII replaces the board with one in the ExportASCII format. The
+// mines under hidden cells are worked out from the numbers; a hidden cell
+// the numbers leave open is a mine only if it is flagged. It fails if the
+// numbers cannot all be satisfied.
+func (b *board) ImportASCII(s string) error {
+	rows := strings.Split(strings.TrimRight(strings.ReplaceAll(s, "\r", ""), "\n"), "\n")
+	h, w := len(rows), len(rows[0])
+	if w < 1 || w > maxBoardW || h > maxBoardH {
+		return fmt.Errorf("unsupported board size %dx%d", w, h)
+	}
+	nb := board{W: w, H: h, ConnectivityMode: b.ConnectivityMode, placed: true}
+	nb.cells = make([][]cell, h)
+	// known holds what is settled about each cell so far
+	const (
+		unknown = iota
+		safe
+		mine
+	)
+	known := make([][]int, h)
+	for y, row := range rows {
+		if len(row) != w {
+			return fmt.Errorf("row %d has %d cells, want %d", y, len(row), w)
+		}
+		nb.cells[y] = make([]cell, w)
+		known[y] = make([]int, w)
+		for x := 0; x < w; x++ {
+			c := &nb.cells[y][x]
+			switch ch := row[x]; {
+			case ch == '.':
+			case ch == 'F':
+				c.State = StateFlagged
+			case ch == '?':
+				c.State = StateQuestion
+			case ch == '*':
+				c.State = StateRevealed
+				known[y][x] = mine
+			case ch == 'X':
+				c.State = StateExploded
+				known[y][x] = mine
+			case ch == ' ':
+				c.State = StateRevealed
+				known[y][x] = safe
+			case ch >= '1' && ch <= '8':
+				c.State = StateRevealed
+				c.Adjacent = int(ch - '0')
+				known[y][x] = safe
+			default:
+				return fmt.Errorf("unexpected %q at (%d,%d)", ch, x, y)
+			}
+		}
+	}
+
+	// settle hidden cells from the numbers until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := nb.cells[y][x]
+				if !c.Revealed() || known[y][x] != safe {
+					continue
+				}
+				need := c.Adjacent
+				var open []point
+				nb.around(x, y, func(nx, ny int) {
+					switch known[ny][nx] {
+					case unknown:
+						open = append(open, point{X: nx, Y: ny})
+					case mine:
+						need--
+					}
+				})
+				if need < 0 || need > len(open) {
+					return fmt.Errorf("cell (%d,%d) shows %d, which the cells around it cannot match", x, y, c.Adjacent)
+				}
+				if len(open) == 0 || (need > 0 && need < len(open)) {
+					continue
+				}
+				settled := safe
+				if need > 0 {
+					settled = mine
+				}
+				for _, p := range open {
+					known[p.Y][p.X] = settled
+				}
+				changed = true
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := &nb.cells[y][x]
+			c.Mine = known[y][x] == mine || (known[y][x] == unknown && c.Flagged())
+			if c.Mine {
+				nb.Mines++
+			}
+			if c.Revealed() && !c.Mine {
+				nb.revealedCnt++
+			}
+			if c.Flagged() {
+				nb.flagsCnt++
+			}
+		}
+	}
+	// keep the shown numbers and check them against the layout
+	shown := make([][]int, h)
+	for y := range shown {
+		shown[y] = make([]int, w)
+		for x := range shown[y] {
+			shown[y][x] = nb.cells[y][x].Adjacent
+		}
+	}
+	nb.computeAdjacent()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := nb.cells[y][x]
+			if c.Revealed() && !c.Mine && c.Adjacent != shown[y][x] {
+				return fmt.Errorf("cell (%d,%d) shows %d but has %d mines around it", x, y, shown[y][x], c.Adjacent)
+			}
+		}
+	}
+	nb.SetSeed(b.seed)
+	if err := nb.Validate(); err != nil {
+		return err
+	}
+	*b = nb
+	return nil
+}
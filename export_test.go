@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestToTikZ(t *testing.T) {
+	rows := []string{
+		"*..",
+		"...",
+		"..*",
+	}
+	tests := []struct {
+		name      string
+		open      [][2]int
+		flags     [][2]int
+		showMines bool
+		wantDraws int
+		wantMines int
+	}{
+		{name: "all hidden", wantDraws: 9},
+		{name: "all hidden, mines shown", showMines: true, wantDraws: 9, wantMines: 2},
+		{name: "one flag adds its pole", flags: [][2]int{{0, 0}}, wantDraws: 10},
+		{name: "two flags", flags: [][2]int{{0, 0}, {2, 2}}, wantDraws: 11},
+		{
+			name:      "numbers are nodes",
+			open:      [][2]int{{1, 0}, {1, 1}, {0, 1}},
+			flags:     [][2]int{{0, 0}},
+			showMines: true,
+			wantDraws: 10,
+			wantMines: 1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t, rows...)
+			for _, p := range tc.open {
+				openCell(t, b, p[0], p[1])
+			}
+			for _, p := range tc.flags {
+				flagCell(t, b, p[0], p[1])
+			}
+			out := b.ToTikZ(tc.showMines)
+			if !utf8.ValidString(out) {
+				t.Fatal("output is not valid UTF-8")
+			}
+			if !strings.HasPrefix(out, "\\begin{tikzpicture}") || !strings.HasSuffix(out, "\\end{tikzpicture}\n") {
+				t.Fatalf("output is not a tikzpicture:\n%s", out)
+			}
+			if got := strings.Count(out, "\\draw"); got != tc.wantDraws {
+				t.Fatalf("%d \\draw commands, want %d", got, tc.wantDraws)
+			}
+			if got := strings.Count(out, "circle"); got != tc.wantMines {
+				t.Fatalf("%d mines drawn, want %d", got, tc.wantMines)
+			}
+			if got := strings.Count(out, "\\node"); got != len(tc.open) {
+				t.Fatalf("%d nodes, want one per opened number (%d)", got, len(tc.open))
+			}
+			if again := b.ToTikZ(tc.showMines); again != out {
+				t.Fatal("output differs between calls")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// cellSizeExplicit is set when --cell-size was given; fullscreen then keeps
+// that size instead of scaling the cells up.
+var cellSizeExplicit bool
+
+// windowedState is what F11 puts back when leaving fullscreen.
+type windowedState struct {
+	w, h     int
+	cellSize int
+	boardW   int
+	boardH   int
+}
+
+// toggleFullscreen switches between the window and fullscreen. Fullscreen
+// scales the cells by the largest whole factor that fits and centres the
+// game on black.
+func (g *game) toggleFullscreen() {
+	g.fullscreen = !g.fullscreen
+	if g.fullscreen {
+		w, h := ebiten.WindowSize()
+		g.windowed = windowedState{w: w, h: h, cellSize: cellSizePx, boardW: g.b.W, boardH: g.b.H}
+		if !cellSizeExplicit {
+			cellSizePx = g.fullscreenCellSize()
+		}
+		g.view.offX, g.view.offY = 0, 0
+		ebiten.SetFullscreen(true)
+		return
+	}
+	ebiten.SetFullscreen(false)
+	cellSizePx = g.windowed.cellSize
+	if g.b.W != g.windowed.boardW || g.b.H != g.windowed.boardH {
+		// the board changed while in fullscreen; the old size no longer fits
+		g.resizeWindow()
+		return
+	}
+	g.view.outsideW, g.view.outsideH = 0, 0
+	ebiten.SetWindowSize(g.windowed.w, g.windowed.h)
+}
+
+// fullscreenCellSize is the default cell size times the largest integer
+// that still fits the board on the screen.
+func (g *game) fullscreenCellSize() int {
+	sw, sh := ebiten.ScreenSizeInFullscreen()
+	scale := min((sw-outerPadding*2)/(g.b.W*defaultCellSize), (sh-topPanelHeight-outerPadding*2)/(g.b.H*defaultCellSize))
+	return clamp(defaultCellSize*max(scale, 1), minCellSize, maxCellSize)
+}
+
+// letterboxOffset is where the game sits inside a fullscreen screen; it is
+// zero in a window.
+func (g *game) letterboxOffset() (int, int) {
+	if !g.fullscreen || g.view.outsideW == 0 {
+		return 0, 0
+	}
+	w, h := g.viewSize()
+	return max((g.view.outsideW-w)/2, 0), max((g.view.outsideH-h)/2, 0)
+}
+
+// drawLetterboxed draws the game into an offscreen frame and centres it on
+// a black screen.
+func (g *game) drawLetterboxed(screen *ebiten.Image) {
+	w, h := g.viewSize()
+	if g.frame == nil || g.frame.Bounds().Dx() != w || g.frame.Bounds().Dy() != h {
+		if g.frame != nil {
+			g.frame.Deallocate()
+		}
+		g.frame = ebiten.NewImage(w, h)
+	}
+	g.frame.Clear()
+	g.drawGame(g.frame)
+	screen.Fill(color.Black)
+	ox, oy := g.letterboxOffset()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(ox), float64(oy))
+	screen.DrawImage(g.frame, op)
+}
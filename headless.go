@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// newHeadlessGame is a game for RunHeadless: no window, and nothing read
+// from or written to the config directory.
+func newHeadlessGame() *game {
+	g := &game{
+		diff:           presets[0],
+		allowQuestion:  true,
+		bestScores:     map[string]ScoreEntry{},
+		stats:          map[string]*Stats{},
+		UndoDepth:      defaultUndoDepth,
+		RevealPerFrame: defaultRevealPerFrame,
+		headless:       true,
+		SafeZone:       safeZone,
+		// scripts comparing runs need the same hint every time
+		HintDeterministic: true,
+	}
+	g.b = newBoard(g.diff.W, g.diff.H, g.diff.Mines)
+	g.subscribeReplay()
+	g.subscribeEffects()
+	g.reset(false)
+	return g
+}
+
+// RunHeadless plays moves read one per line, "reveal X Y", "flag X Y",
+// "chord X Y" or "reset", and after each one writes the board to stdout in
+// the ExportASCII format followed by a blank line. A line "svg" writes the
+// board with ExportSVG instead, and a line "hint" writes "hint X Y reason"
+// for the cell H would point at. Blank lines and lines starting with # are
+// skipped. It stops at the first line it cannot parse.
+func (g *game) RunHeadless(moves io.Reader) error {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	sc := bufio.NewScanner(moves)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "svg" {
+			if err := g.ExportSVG(out); err != nil {
+				return err
+			}
+			continue
+		}
+		if line == "hint" {
+			if x, y, reason, ok := g.b.findSafeHint(g.HintDeterministic); ok && g.state == statePlaying {
+				fmt.Fprintf(out, "hint %d %d %s\n", x, y, reason)
+			} else {
+				fmt.Fprintln(out, "hint none")
+			}
+			continue
+		}
+		if err := g.headlessMove(strings.Fields(line)); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		fmt.Fprintln(out, g.b.ExportASCII())
+	}
+	return sc.Err()
+}
+
+func (g *game) headlessMove(f []string) error {
+	if f[0] == "reset" {
+		if len(f) != 1 {
+			return fmt.Errorf("reset takes no arguments")
+		}
+		g.reset(false)
+		return nil
+	}
+	if len(f) != 3 {
+		return fmt.Errorf("want %q X Y", f[0])
+	}
+	x, errX := strconv.Atoi(f[1])
+	y, errY := strconv.Atoi(f[2])
+	if errX != nil || errY != nil || !g.b.in(x, y) {
+		return fmt.Errorf("bad cell %s %s on a %dx%d board", f[1], f[2], g.b.W, g.b.H)
+	}
+	if g.state != statePlaying {
+		// like the window, a finished game ignores moves until reset
+		return nil
+	}
+	switch f[0] {
+	case "reveal":
+		if !g.b.placed && !g.b.cells[y][x].Flagged() {
+			// the window places mines in the background; here it can wait
+			g.b.placeMines(x, y)
+		}
+		g.revealCell(x, y)
+		for len(g.revealQueue) > 0 && g.state == statePlaying {
+			g.stepRevealQueue()
+		}
+	case "flag":
+		g.markCell(x, y)
+	case "chord":
+		g.chordCell(x, y)
+	default:
+		return fmt.Errorf("unknown move %q", f[0])
+	}
+	return nil
+}
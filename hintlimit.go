@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// defaultHintLimit is how many hints a game above Beginner gets.
+const defaultHintLimit = 3
+
+// hintLimit is the --hints flag: the hints every game gets, or below zero
+// the default, which leaves Beginner unlimited.
+var hintLimit = -1
+
+// assistedMark is put on a record set in a game that used a hint.
+const assistedMark = "*"
+
+// hintsFor is how many hints a game on d starts with; -1 is unlimited.
+func hintsFor(d difficulty) int {
+	if hintLimit >= 0 {
+		return hintLimit
+	}
+	if d.Name == presets[0].Name {
+		return -1
+	}
+	return defaultHintLimit
+}
+
+// hintAvailable reports whether H may give a hint, and says so when the
+// game has used them all.
+func (g *game) hintAvailable() bool {
+	if g.hintsRemaining == 0 {
+		g.showToast("No hints remaining")
+		return false
+	}
+	return true
+}
+
+// spendHint counts a hint that was given.
+func (g *game) spendHint() {
+	g.hintUsed = true
+	if g.hintsRemaining > 0 {
+		g.hintsRemaining--
+	}
+}
+
+// scoreMark is the mark a record set by this game carries.
+func (g *game) scoreMark() string {
+	if g.hintUsed {
+		return assistedMark
+	}
+	return ""
+}
+
+// drawHintBadge shows the hints left in a small tag on the left corner of
+// the face button, opposite the click badge.
+func (g *game) drawHintBadge(screen *ebiten.Image, faceX, faceY int, th theme) {
+	s := "H" + strconv.Itoa(g.hintsRemaining)
+	w := len(s)*7 + 4
+	x, y := faceX-w/2, faceY-6
+	bg := rgb(30, 110, 200)
+	if g.hintsRemaining == 0 {
+		bg = th.Dark
+	}
+	ebitenutil.DrawRect(screen, float64(x), float64(y), float64(w), 14, bg)
+	drawTextCentered(screen, s, g.fontMain, x, y, w, rgb(255, 255, 255))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// toggleLock locks or unlocks the hidden cell (x,y). Open cells cannot be
+// locked.
+func (b *board) toggleLock(x, y int) bool {
+	if !b.in(x, y) || b.cells[y][x].Revealed() {
+		return false
+	}
+	b.cells[y][x].Locked = !b.cells[y][x].Locked
+	return true
+}
+
+// ClearLocks takes every lock off the board.
+func (b *board) ClearLocks() {
+	for y := range b.cells {
+		for x := range b.cells[y] {
+			b.cells[y][x].Locked = false
+		}
+	}
+}
+
+// handleLockAt locks or unlocks the cell under a Ctrl+right click.
+func (g *game) handleLockAt(mx, my int) bool {
+	mx, my = g.normalizeInputPos(mx, my)
+	if g.paused || g.state != statePlaying || g.showHelp || g.showScores {
+		return false
+	}
+	x, y, ok := g.boardPosFromCursor(mx, my)
+	if !ok {
+		return false
+	}
+	return g.lockCell(x, y)
+}
+
+// lockCell locks or unlocks (x,y) for the player and records the move.
+func (g *game) lockCell(x, y int) bool {
+	if !g.b.toggleLock(x, y) {
+		return false
+	}
+	g.events.Publish(MoveEvent{Action: ReplayLock, X: x, Y: y})
+	return true
+}
+
+// drawPadlock marks a locked cell with a small padlock in its bottom-right
+// corner, clear of a flag.
+func drawPadlock(screen *ebiten.Image, px, py int, th theme) {
+	s := float32(cellSizePx) / defaultCellSize
+	x, y := float32(px)+15*s, float32(py)+13*s
+	vector.StrokeCircle(screen, x+3*s, y+3*s, 2.5*s, 1.5, th.CellText, false)
+	vector.DrawFilledRect(screen, x, y+3*s, 6*s, 5*s, th.CellText, false)
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"math"
 	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -21,14 +30,37 @@ import (
 	"golang.org/x/image/font/basicfont"
 )
 
+// cellSizePx is the side of one board cell in pixels, set by --cell-size and
+// the +/- keys within [minCellSize, maxCellSize].
+var cellSizePx = defaultCellSize
+
 const (
-	cellSize          = 24
-	outerPadding      = 12
-	topPanelHeight    = 68
-	touchMoveSlopPx   = 10
-	touchLongPressDur = 360 * time.Millisecond
+	defaultCellSize = 24
+	minCellSize     = 12
+	maxCellSize     = 64
+	outerPadding    = 12
+	topPanelHeight  = 68
+	// cascades drip open defaultRevealPerFrame cells a tick, each fading in
+	// over revealFadeFrames ticks
+	defaultRevealPerFrame = 4
+	revealFadeFrames      = 10
+	fastRevealThreshold   = 50
+	// held arrow keys repeat after keyRepeatDelay ticks, then every
+	// keyRepeatInterval ticks
+	keyRepeatDelay    = 15
+	keyRepeatInterval = 4
+	// below this cell size the drop shadow under hidden cells is just noise
+	shadowMinCellSize = 24
 )
 
+// texExportPath is where Ctrl+Shift+T writes the TikZ export; empty means
+// board.tex in the config directory.
+var texExportPath string
+
+// svgExportPath is where Ctrl+Shift+S writes the SVG export; empty means
+// board.svg in the config directory.
+var svgExportPath string
+
 type gameState int
 
 const (
@@ -41,6 +73,13 @@ type difficulty struct {
 	Name  string
 	W, H  int
 	Mines int
+	// Connectivity is the cascade mode given to the board; presets use
+	// the zero value, Connectivity8.
+	Connectivity int
+	// Daily is the date (YYYY-MM-DD) of a daily challenge, empty otherwise.
+	Daily string
+	// Puzzle names the --puzzle file the board came from, empty otherwise.
+	Puzzle string
 }
 
 var presets = []difficulty{
@@ -49,50 +88,125 @@ var presets = []difficulty{
 	{Name: "Expert", W: 30, H: 16, Mines: 99},
 }
 
-type cell struct {
-	Mine      bool
-	Revealed  bool
-	Flagged   bool
-	Question  bool
-	Adjacent  int
-	Exploded  bool
-	WrongFlag bool
-}
+// Cascade modes for board.ConnectivityMode. Adjacent counts always use all
+// eight neighbours; only the flood fill from a zero differs.
+const (
+	Connectivity8 = iota
+	Connectivity4
+)
 
 type board struct {
-	W, H        int
-	Mines       int
-	cells       [][]cell
-	placed      bool
+	W, H  int
+	Mines int
+	// ConnectivityMode picks which neighbours a zero opens.
+	ConnectivityMode int
+	cells            [][]cell
+	placed           bool
+	start            point
+	// retainLayout makes the next reset keep the mines so the same layout
+	// can be played again.
+	retainLayout bool
+	// pregenerated marks a layout built ahead of time around the centre
+	// cell; it is redone if the first click lands elsewhere.
+	pregenerated bool
+	// Solvable keeps regenerating the layout, up to SolvableRetries times,
+	// until it can be cleared from the first click without guessing.
+	Solvable        bool
+	SolvableRetries int
+	// Toroidal wraps the edges: the first column touches the last and the
+	// top row touches the bottom one.
+	Toroidal bool
+	// SymmetricMines lays mines out with 180-degree symmetry about the
+	// centre; see placeSymmetric.
+	SymmetricMines bool
+	// ClusteredMines gathers mines in clumps instead of spreading them
+	// evenly; see clusterOrder.
+	ClusteredMines bool
+	// safePadding is how far the first click's safe zone reaches: 1 keeps
+	// the 3x3 block around it clear, 2 a 5x5 block, 0 nothing at all.
+	safePadding int
 	revealedCnt int
 	flagsCnt    int
+	seed        int64
+	rng         *rand.Rand
 }
 
 func newBoard(w, h, mines int) *board {
-	b := &board{}
+	b := &board{safePadding: defaultSafePadding}
 	b.configure(w, h, mines)
 	return b
 }
 
 func (b *board) configure(w, h, mines int) {
 	b.W, b.H = w, h
-	maxMines := w*h - 1
-	if mines < 1 {
-		mines = 1
-	}
-	if mines > maxMines {
-		mines = maxMines
-	}
-	b.Mines = mines
+	b.Mines = clamp(mines, 1, b.maxMines())
 	b.reset()
 }
 
+// maxMines is the most mines the board can take, leaving room for the safe
+// zone when it fits on the board at all.
+func (b *board) maxMines() int {
+	if side := 2*b.safePadding + 1; b.safePadding > 0 && side <= b.W && side <= b.H {
+		return b.W*b.H - side*side
+	}
+	return b.W*b.H - 1
+}
+
+// SetSeed makes the next mine placement reproducible from seed.
+func (b *board) SetSeed(seed int64) {
+	b.seed = seed
+	b.rng = rand.New(rand.NewSource(seed))
+}
+
 func (b *board) reset() {
+	if b.retainLayout && b.placed {
+		b.resetKeepMines()
+		return
+	}
+	b.SetSeed(rand.Int63())
 	b.cells = make([][]cell, b.H)
 	for y := range b.cells {
 		b.cells[y] = make([]cell, b.W)
 	}
 	b.placed = false
+	b.pregenerated = false
+	b.revealedCnt = 0
+	b.flagsCnt = 0
+}
+
+// blank returns a fresh, unplaced board with the same configuration.
+func (b *board) blank() *board {
+	nb := newBoard(b.W, b.H, b.Mines)
+	nb.safePadding = b.safePadding
+	nb.ConnectivityMode = b.ConnectivityMode
+	nb.Solvable, nb.SolvableRetries = b.Solvable, b.SolvableRetries
+	nb.Toroidal = b.Toroidal
+	nb.SymmetricMines = b.SymmetricMines
+	nb.ClusteredMines = b.ClusteredMines
+	return nb
+}
+
+// clearMines removes the layout but keeps any marks already placed.
+func (b *board) clearMines() {
+	for y := range b.cells {
+		for x := range b.cells[y] {
+			b.cells[y][x].Mine = false
+			b.cells[y][x].Adjacent = 0
+		}
+	}
+	b.placed = false
+	b.pregenerated = false
+	b.SetSeed(rand.Int63())
+}
+
+// resetKeepMines clears all player progress but leaves Mine and Adjacent
+// untouched.
+func (b *board) resetKeepMines() {
+	for y := range b.cells {
+		for x := range b.cells[y] {
+			b.cells[y][x].State = StateHidden
+		}
+	}
 	b.revealedCnt = 0
 	b.flagsCnt = 0
 }
@@ -101,25 +215,166 @@ func (b *board) in(x, y int) bool {
 	return x >= 0 && y >= 0 && x < b.W && y < b.H
 }
 
+// around visits the up to 8 cells touching (x, y), diagonals included.
 func (b *board) around(x, y int, fn func(nx, ny int)) {
+	b.neighbours(x, y, true, fn)
+}
+
+// cardinal visits only the up to 4 cells sharing an edge with (x, y).
+func (b *board) cardinal(x, y int, fn func(nx, ny int)) {
+	b.neighbours(x, y, false, fn)
+}
+
+// cascade visits the neighbours a revealed zero opens under the board's
+// ConnectivityMode.
+func (b *board) cascade(x, y int, fn func(nx, ny int)) {
+	b.neighbours(x, y, b.ConnectivityMode != Connectivity4, fn)
+}
+
+func (b *board) neighbours(x, y int, includeDiags bool, fn func(nx, ny int)) {
+	// on a torus narrower than three cells two offsets can wrap onto the
+	// same cell, or back onto (x, y); each neighbour is visited once
+	var seen [8][2]int
+	n := 0
 	for dy := -1; dy <= 1; dy++ {
 		for dx := -1; dx <= 1; dx++ {
 			if dx == 0 && dy == 0 {
 				continue
 			}
+			if !includeDiags && dx != 0 && dy != 0 {
+				continue
+			}
 			nx, ny := x+dx, y+dy
-			if b.in(nx, ny) {
-				fn(nx, ny)
+			if !b.Toroidal {
+				if b.in(nx, ny) {
+					fn(nx, ny)
+				}
+				continue
+			}
+			nx, ny = (nx+b.W)%b.W, (ny+b.H)%b.H
+			dup := nx == x && ny == y
+			for _, p := range seen[:n] {
+				dup = dup || p == [2]int{nx, ny}
+			}
+			if dup {
+				continue
 			}
+			seen[n] = [2]int{nx, ny}
+			n++
+			fn(nx, ny)
 		}
 	}
 }
 
+// near reports whether (x,y) is in the first click's safe zone around
+// (cx,cy), the block reaching safePadding cells out, wrapping across the
+// edges of a toroidal board. With no padding nothing is near.
+func (b *board) near(x, y, cx, cy int) bool {
+	if b.safePadding <= 0 {
+		return false
+	}
+	dx, dy := absInt(x-cx), absInt(y-cy)
+	if b.Toroidal {
+		dx, dy = min(dx, b.W-dx), min(dy, b.H-dy)
+	}
+	return dx <= b.safePadding && dy <= b.safePadding
+}
+
 func (b *board) placeMines(sx, sy int) {
+	b.placeMinesCtx(context.Background(), sx, sy)
+}
+
+// placeMinesAsync lays out the mines on a copy of b in a goroutine. The
+// channel delivers the copy, or is closed without a value if ctx is
+// cancelled first. b itself is not touched; see adoptLayout.
+func (b *board) placeMinesAsync(ctx context.Context, sx, sy int) <-chan *board {
+	nb := b.blank()
+	// a generator of its own, so b's is never drawn from on two goroutines
+	nb.SetSeed(b.seed)
+	out := make(chan *board, 1)
+	go func() {
+		defer close(out)
+		if nb.placeMinesCtx(ctx, sx, sy) {
+			out <- nb
+		}
+	}()
+	return out
+}
+
+// adoptLayout copies the mines placed on src into b, and their count,
+// keeping b's marks.
+func (b *board) adoptLayout(src *board) {
+	for y := range b.cells {
+		for x := range b.cells[y] {
+			b.cells[y][x].Mine = src.cells[y][x].Mine
+			b.cells[y][x].Adjacent = src.cells[y][x].Adjacent
+		}
+	}
+	b.Mines = src.Mines
+	b.placed = true
+	b.start = src.start
+}
+
+// defaultSolvableRetries is how many layouts a Solvable board tries, per
+// round, before settling for one that needs a guess.
+const defaultSolvableRetries = 500
+
+// placeMinesCtx places the mines keeping (sx,sy) and its neighbours clear,
+// retrying while a Solvable board's layout needs a guess. If a round of
+// retries finds nothing, a second round keeps only (sx,sy) clear, which
+// allows layouts the safe zone rules out. If that fails too, it settles for
+// a fresh layout with the whole safe zone clear, even though it needs a
+// guess. It reports false if ctx was cancelled first.
+func (b *board) placeMinesCtx(ctx context.Context, sx, sy int) bool {
+	if !b.layoutMinesCtx(ctx, sx, sy, false) {
+		return false
+	}
+	if !b.Solvable {
+		return true
+	}
+	retries := b.SolvableRetries
+	if retries <= 0 {
+		retries = defaultSolvableRetries
+	}
+	regenerate := func(relaxed bool) bool {
+		for y := range b.cells {
+			for x := range b.cells[y] {
+				b.cells[y][x].Mine = false
+			}
+		}
+		return b.layoutMinesCtx(ctx, sx, sy, relaxed)
+	}
+	rounds := []bool{false}
+	if b.safePadding > 0 {
+		// with no safe zone the relaxed round would lay out the same way
+		rounds = append(rounds, true)
+	}
+	for _, relaxed := range rounds {
+		for try := 1; try <= retries; try++ {
+			if b.IsSolvable() {
+				return true
+			}
+			debugf("layout %d needs a guess, regenerating (relaxed: %v)", try, relaxed)
+			if !regenerate(relaxed) {
+				return false
+			}
+		}
+	}
+	if b.IsSolvable() {
+		return true
+	}
+	debugf("no layout without a guess, settling for one with the safe zone clear")
+	return regenerate(false)
+}
+
+// layoutMinesCtx lays out one set of mines; relaxed keeps only (sx,sy)
+// clear instead of its safe zone. It checks ctx every 100 shuffle steps and
+// reports false if it gave up.
+func (b *board) layoutMinesCtx(ctx context.Context, sx, sy int, relaxed bool) bool {
 	var candidates [][2]int
 	for y := 0; y < b.H; y++ {
 		for x := 0; x < b.W; x++ {
-			if int(math.Abs(float64(x-sx))) <= 1 && int(math.Abs(float64(y-sy))) <= 1 {
+			if relaxed && x == sx && y == sy || !relaxed && b.near(x, y, sx, sy) {
 				continue
 			}
 			candidates = append(candidates, [2]int{x, y})
@@ -130,7 +385,7 @@ func (b *board) placeMines(sx, sy int) {
 		candidates = candidates[:0]
 		for y := 0; y < b.H; y++ {
 			for x := 0; x < b.W; x++ {
-				if x == sx && y == sy {
+				if x == sx && y == sy && b.safePadding > 0 {
 					continue
 				}
 				candidates = append(candidates, [2]int{x, y})
@@ -138,15 +393,128 @@ func (b *board) placeMines(sx, sy int) {
 		}
 	}
 
-	rand.Shuffle(len(candidates), func(i, j int) {
+	for i := len(candidates) - 1; i > 0; i-- {
+		if i%100 == 0 && ctx.Err() != nil {
+			return false
+		}
+		j := b.rng.Intn(i + 1)
 		candidates[i], candidates[j] = candidates[j], candidates[i]
-	})
+	}
+
+	b.Mines = b.layoutMines()
+	if b.ClusteredMines {
+		candidates = b.clusterOrder(candidates)
+	}
+	if b.SymmetricMines {
+		b.placeSymmetric(candidates)
+	} else {
+		for i := 0; i < b.Mines && i < len(candidates); i++ {
+			p := candidates[i]
+			b.cells[p[1]][p[0]].Mine = true
+		}
+	}
+	b.computeAdjacent()
+	b.start = point{X: sx, Y: sy}
+	b.placed = true
+	return true
+}
+
+// layoutMines is how many mines a layout gets: Mines, rounded down to an
+// even count for a symmetric one.
+func (b *board) layoutMines() int {
+	if b.SymmetricMines && b.Mines > 1 {
+		return b.Mines &^ 1
+	}
+	return b.Mines
+}
+
+// Clustered layouts seed one cluster per clusterSize mines and scatter the
+// mines around them with a standard deviation of clusterSigma cells.
+const (
+	clusterSize  = 5
+	clusterSigma = 2.0
+)
+
+// clusterOrder reorders the shuffled candidates so that the first Mines of
+// them fall in clusters: each is drawn from a normal distribution around a
+// random cluster centre, drawing again when it lands off the board, outside
+// the candidates or on a cell already taken. The other candidates follow,
+// so a placement that takes from the front ends up clustered.
+func (b *board) clusterOrder(candidates [][2]int) [][2]int {
+	if len(candidates) == 0 {
+		return candidates
+	}
+	allowed := make(map[[2]int]bool, len(candidates))
+	for _, p := range candidates {
+		allowed[p] = true
+	}
+	// the candidates are shuffled, so their head is a random pick of centres
+	centres := candidates[:min(max(b.Mines/clusterSize, 1), len(candidates))]
+	out := make([][2]int, 0, len(candidates))
+	taken := map[[2]int]bool{}
+	// a dense board may not have room around its centres; give up on
+	// clustering the rest rather than draw forever
+	for tries := 0; len(out) < b.Mines && tries < 100*b.Mines; tries++ {
+		c := centres[b.rng.Intn(len(centres))]
+		p := [2]int{
+			c[0] + int(math.Round(b.rng.NormFloat64()*clusterSigma)),
+			c[1] + int(math.Round(b.rng.NormFloat64()*clusterSigma)),
+		}
+		if !allowed[p] || taken[p] {
+			continue
+		}
+		taken[p] = true
+		out = append(out, p)
+	}
+	for _, p := range candidates {
+		if !taken[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
 
-	for i := 0; i < b.Mines && i < len(candidates); i++ {
-		p := candidates[i]
+// placeSymmetric puts Mines mines on the shuffled candidates, each with its
+// mirror image through the centre, (W-1-x, H-1-y). A cell whose mirror is
+// not a candidate, being in the safe zone or the cell itself, gets a mine
+// of its own. Only a board too full for pairs is left short of symmetry.
+func (b *board) placeSymmetric(candidates [][2]int) {
+	allowed := make(map[[2]int]bool, len(candidates))
+	for _, p := range candidates {
+		allowed[p] = true
+	}
+	placed := 0
+	for _, p := range candidates {
+		if placed == b.Mines {
+			return
+		}
+		if b.cells[p[1]][p[0]].Mine {
+			continue
+		}
+		q := [2]int{b.W - 1 - p[0], b.H - 1 - p[1]}
+		if q != p && allowed[q] {
+			if placed+2 > b.Mines {
+				continue
+			}
+			b.cells[q[1]][q[0]].Mine = true
+			placed++
+		}
 		b.cells[p[1]][p[0]].Mine = true
+		placed++
+	}
+	for _, p := range candidates {
+		if placed == b.Mines {
+			return
+		}
+		if !b.cells[p[1]][p[0]].Mine {
+			b.cells[p[1]][p[0]].Mine = true
+			placed++
+		}
 	}
+}
 
+// computeAdjacent fills in Adjacent for every safe cell from the mines.
+func (b *board) computeAdjacent() {
 	for y := 0; y < b.H; y++ {
 		for x := 0; x < b.W; x++ {
 			if b.cells[y][x].Mine {
@@ -161,75 +529,140 @@ func (b *board) placeMines(sx, sy int) {
 			b.cells[y][x].Adjacent = count
 		}
 	}
-	b.placed = true
 }
 
-func (b *board) reveal(x, y int) (hitMine, changed bool) {
+// DensityByRing returns the mine density of each Chebyshev ring around
+// (cx, cy). Ring 0 is the 3×3 block centred on the cell, ring 1 the cells
+// two steps away, and so on. Ring 0 is mine-free around a first click only
+// while the safe zone is on.
+func (b *board) DensityByRing(cx, cy int) []float64 {
+	var mines, cells []int
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			d := max(absInt(x-cx), absInt(y-cy))
+			r := max(d-1, 0)
+			for len(cells) <= r {
+				cells = append(cells, 0)
+				mines = append(mines, 0)
+			}
+			cells[r]++
+			if b.cells[y][x].Mine {
+				mines[r]++
+			}
+		}
+	}
+	out := make([]float64, len(cells))
+	for i := range cells {
+		out[i] = float64(mines[i]) / float64(cells[i])
+	}
+	return out
+}
+
+// prepareReveal checks that (x,y) can be opened and makes sure the mines
+// are in place for it.
+func (b *board) prepareReveal(x, y int) bool {
 	if !b.in(x, y) {
-		return false, false
+		return false
 	}
 	c := &b.cells[y][x]
-	if c.Revealed || c.Flagged {
-		return false, false
+	if c.Revealed() || c.Flagged() || c.Locked {
+		return false
+	}
+	if b.pregenerated && b.revealedCnt == 0 && (x != b.start.X || y != b.start.Y) {
+		// the layout was built around the centre: its safe zone, and a
+		// no-guess check, only hold for a first click there
+		b.clearMines()
 	}
 	if !b.placed {
 		b.placeMines(x, y)
 	}
+	return true
+}
 
-	if c.Mine {
-		c.Revealed = true
-		c.Exploded = true
-		return true, true
+// planReveal works out what opening (x,y) uncovers without opening it: the
+// clicked cell and, from a zero, the flood fill in breadth-first order,
+// which flags and locked cells stop. Mine
+// placement for a first click happens here. Hitting a mine is not deferred:
+// the mine explodes at once and is the only cell returned.
+func (b *board) planReveal(x, y int) (hitMine bool, cells [][2]int) {
+	if !b.prepareReveal(x, y) {
+		return false, nil
+	}
+	if b.cells[y][x].Mine {
+		b.cells[y][x].SetExploded()
+		return true, [][2]int{{x, y}}
 	}
 
+	seen := make([][]bool, b.H)
+	for i := range seen {
+		seen[i] = make([]bool, b.W)
+	}
+	seen[y][x] = true
 	queue := [][2]int{{x, y}}
 	for len(queue) > 0 {
 		p := queue[0]
 		queue = queue[1:]
-		cx, cy := p[0], p[1]
-		cc := &b.cells[cy][cx]
-		if cc.Revealed || cc.Flagged {
+		cells = append(cells, p)
+		if b.cells[p[1]][p[0]].Adjacent != 0 {
 			continue
 		}
-		cc.Revealed = true
-		cc.Question = false
-		b.revealedCnt++
-		changed = true
+		b.cascade(p[0], p[1], func(nx, ny int) {
+			nc := &b.cells[ny][nx]
+			if !seen[ny][nx] && !nc.Revealed() && !nc.Flagged() && !nc.Locked {
+				seen[ny][nx] = true
+				queue = append(queue, [2]int{nx, ny})
+			}
+		})
+	}
+	return false, cells
+}
 
-		if cc.Adjacent == 0 {
-			b.around(cx, cy, func(nx, ny int) {
-				nc := &b.cells[ny][nx]
-				if !nc.Revealed && !nc.Flagged {
-					queue = append(queue, [2]int{nx, ny})
-				}
-			})
-		}
+// revealOne opens a single cell, with no cascade.
+func (b *board) revealOne(x, y int) bool {
+	if !b.cells[y][x].Reveal() {
+		return false
 	}
+	b.revealedCnt++
+	return true
+}
 
+func (b *board) reveal(x, y int) (hitMine, changed bool) {
+	defer b.debugCheck("reveal")
+	hit, cells := b.planReveal(x, y)
+	if hit {
+		return true, true
+	}
+	for _, p := range cells {
+		if b.revealOne(p[0], p[1]) {
+			changed = true
+		}
+	}
 	return false, changed
 }
 
 func (b *board) toggleMark(x, y int, allowQuestion bool) bool {
+	defer b.debugCheck("toggleMark")
 	if !b.in(x, y) {
 		return false
 	}
 	c := &b.cells[y][x]
-	if c.Revealed {
+	if c.Revealed() || c.Locked {
 		return false
 	}
 
-	switch {
-	case !c.Flagged && !c.Question:
-		c.Flagged = true
+	switch c.State {
+	case StateHidden:
+		c.Flag()
 		b.flagsCnt++
-	case c.Flagged:
-		c.Flagged = false
+	case StateFlagged:
 		b.flagsCnt--
 		if allowQuestion {
-			c.Question = true
+			c.MarkQuestion()
+		} else {
+			c.Unflag()
 		}
-	case c.Question:
-		c.Question = false
+	case StateQuestion:
+		c.Unflag()
 	}
 	return true
 }
@@ -237,28 +670,65 @@ func (b *board) toggleMark(x, y int, allowQuestion bool) bool {
 func (b *board) countAdjacentFlags(x, y int) int {
 	count := 0
 	b.around(x, y, func(nx, ny int) {
-		if b.cells[ny][nx].Flagged {
+		if b.cells[ny][nx].Flagged() {
 			count++
 		}
 	})
 	return count
 }
 
-func (b *board) chord(x, y int) (hitMine, changed bool) {
+// chordThresholdMet reports whether the flags around (x, y) equal its number
+// plus threshold. A negative threshold allows chording before every mine is
+// flagged; a positive one demands extra flags first.
+func (b *board) chordThresholdMet(x, y, threshold int) bool {
+	return b.countAdjacentFlags(x, y) == b.cells[y][x].Adjacent+threshold
+}
+
+// isCorroborated reports whether the flag at (x, y) completes the constraint
+// of at least two revealed numbers, so more than one number vouches for it.
+func (b *board) isCorroborated(x, y int) bool {
+	if !b.cells[y][x].Flagged() {
+		return false
+	}
+	satisfied := 0
+	b.around(x, y, func(nx, ny int) {
+		nc := b.cells[ny][nx]
+		if nc.Revealed() && !nc.Mine && nc.Adjacent > 0 && b.countAdjacentFlags(nx, ny) == nc.Adjacent {
+			satisfied++
+		}
+	})
+	return satisfied >= 2
+}
+
+// CorroboratedFlags returns every flag for which isCorroborated holds.
+func (b *board) CorroboratedFlags() [][2]int {
+	var out [][2]int
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if b.isCorroborated(x, y) {
+				out = append(out, [2]int{x, y})
+			}
+		}
+	}
+	return out
+}
+
+func (b *board) chord(x, y, threshold int) (hitMine, changed bool) {
+	defer b.debugCheck("chord")
 	if !b.in(x, y) {
 		return false, false
 	}
 	c := b.cells[y][x]
-	if !c.Revealed || c.Adjacent == 0 {
+	if !c.Revealed() || c.Adjacent == 0 {
 		return false, false
 	}
-	if b.countAdjacentFlags(x, y) != c.Adjacent {
+	if !b.chordThresholdMet(x, y, threshold) {
 		return false, false
 	}
 
 	b.around(x, y, func(nx, ny int) {
 		nc := b.cells[ny][nx]
-		if nc.Revealed || nc.Flagged {
+		if nc.Revealed() || nc.Flagged() {
 			return
 		}
 		hit, ch := b.reveal(nx, ny)
@@ -272,77 +742,428 @@ func (b *board) chord(x, y int) (hitMine, changed bool) {
 	return
 }
 
-func (b *board) revealAllMines() {
+// revealAllMines shows the layout after a loss and returns how many flags
+// turned out to be on safe cells.
+func (b *board) revealAllMines() int {
+	wrong := 0
 	for y := 0; y < b.H; y++ {
 		for x := 0; x < b.W; x++ {
 			c := &b.cells[y][x]
 			if c.Mine {
-				c.Revealed = true
-			}
-			if c.Flagged && !c.Mine {
-				c.WrongFlag = true
+				if c.State == StateFlagged {
+					c.Unflag()
+					b.flagsCnt--
+				}
+				c.Reveal()
+			} else if c.SetWrongFlag() {
+				wrong++
 			}
 		}
 	}
+	return wrong
 }
 
 func (b *board) autoFlagMines() {
+	defer b.debugCheck("autoFlagMines")
 	for y := 0; y < b.H; y++ {
 		for x := 0; x < b.W; x++ {
 			c := &b.cells[y][x]
-			if c.Mine && !c.Flagged {
-				c.Flagged = true
+			if c.Mine && c.Flag() {
 				b.flagsCnt++
 			}
 		}
 	}
 }
 
-func (b *board) isWin() bool {
-	return b.revealedCnt == b.W*b.H-b.Mines
+// Compute3BV returns the Bechtel's Board Benchmark Value: the minimum number
+// of left clicks needed to clear the board, i.e. every opening plus every
+// numbered cell that no opening reaches.
+func (b *board) Compute3BV() int {
+	if !b.placed {
+		return 0
+	}
+	seen := make([][]bool, b.H)
+	for y := range seen {
+		seen[y] = make([]bool, b.W)
+	}
+	bv := 0
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			if seen[y][x] || c.Mine || c.Adjacent != 0 {
+				continue
+			}
+			bv++
+			queue := [][2]int{{x, y}}
+			seen[y][x] = true
+			for len(queue) > 0 {
+				p := queue[0]
+				queue = queue[1:]
+				if b.cells[p[1]][p[0]].Adjacent != 0 {
+					continue
+				}
+				b.cascade(p[0], p[1], func(nx, ny int) {
+					if !seen[ny][nx] && !b.cells[ny][nx].Mine {
+						seen[ny][nx] = true
+						queue = append(queue, [2]int{nx, ny})
+					}
+				})
+			}
+		}
+	}
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if !seen[y][x] && !b.cells[y][x].Mine {
+				bv++
+			}
+		}
+	}
+	return bv
 }
 
-func (b *board) remainingMines() int {
-	return b.Mines - b.flagsCnt
+// AutoFlagSingles flags the lone hidden neighbour of every revealed number
+// that is missing exactly one mine and has exactly one candidate left. It is
+// the cheap special case of full constraint propagation and only looks at
+// what the player can see. It returns the cells it flagged.
+func (b *board) AutoFlagSingles() (flagged [][2]int) {
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			if !c.Revealed() || c.Mine || c.Adjacent == 0 {
+				continue
+			}
+			flags := 0
+			var hidden [][2]int
+			b.around(x, y, func(nx, ny int) {
+				nc := b.cells[ny][nx]
+				switch {
+				case nc.Flagged():
+					flags++
+				case !nc.Revealed():
+					hidden = append(hidden, [2]int{nx, ny})
+				}
+			})
+			if len(hidden) != 1 || c.Adjacent-flags != 1 {
+				continue
+			}
+			if b.cells[hidden[0][1]][hidden[0][0]].Flag() {
+				b.flagsCnt++
+				flagged = append(flagged, hidden[0])
+			}
+		}
+	}
+	return flagged
 }
 
-func (b *board) findSafeHint() (int, int, bool) {
-	if !b.placed {
-		return b.W / 2, b.H / 2, true
+// Validate recomputes the board's bookkeeping from its cells (the grid
+// size, Adjacent counts, revealedCnt, flagsCnt and, once placed, the mine
+// count) and returns an error describing the first mismatch. Boards read
+// from files go through it so a bad one is turned away before play.
+func (b *board) Validate() error {
+	if len(b.cells) != b.H {
+		return fmt.Errorf("board has %d rows, want %d", len(b.cells), b.H)
 	}
-	var options [][2]int
+	for y, row := range b.cells {
+		if len(row) != b.W {
+			return fmt.Errorf("row %d has %d cells, want %d", y, len(row), b.W)
+		}
+	}
+	revealed, flags, mines := 0, 0, 0
 	for y := 0; y < b.H; y++ {
 		for x := 0; x < b.W; x++ {
 			c := b.cells[y][x]
-			if c.Revealed || c.Flagged || c.Mine {
+			if c.Revealed() && !c.Mine {
+				revealed++
+			}
+			if c.Flagged() {
+				flags++
+			}
+			if c.Exploded() && !c.Mine {
+				return fmt.Errorf("cell (%d,%d) exploded without a mine", x, y)
+			}
+			if c.Mine {
+				mines++
+			}
+			if !b.placed || c.Mine {
 				continue
 			}
-			options = append(options, [2]int{x, y})
+			n := 0
+			b.around(x, y, func(nx, ny int) {
+				if b.cells[ny][nx].Mine {
+					n++
+				}
+			})
+			if n != c.Adjacent {
+				return fmt.Errorf("cell (%d,%d) has Adjacent=%d, want %d", x, y, c.Adjacent, n)
+			}
 		}
 	}
-	if len(options) == 0 {
-		return 0, 0, false
+	if revealed != b.revealedCnt {
+		return fmt.Errorf("revealedCnt=%d, want %d", b.revealedCnt, revealed)
+	}
+	if flags != b.flagsCnt {
+		return fmt.Errorf("flagsCnt=%d, want %d", b.flagsCnt, flags)
 	}
-	p := options[rand.Intn(len(options))]
-	return p[0], p[1], true
+	if b.placed && mines != b.Mines {
+		return fmt.Errorf("board has %d mines, want %d", mines, b.Mines)
+	}
+	return nil
 }
 
-type point struct{ X, Y int }
+func (b *board) isWin() bool {
+	return b.revealedCnt == b.W*b.H-b.Mines
+}
 
-type touchStart struct {
-	X, Y         int
-	LastX, LastY int
-	At           time.Time
+func (b *board) remainingMines() int {
+	return b.Mines - b.flagsCnt
 }
 
-type theme struct {
-	Name           string
-	BG             color.Color
-	Panel          color.Color
-	Light          color.Color
-	Dark           color.Color
-	CellHidden     color.Color
-	CellRevealed   color.Color
+// frontierMask marks the unrevealed, unflagged cells touching at least one
+// revealed safe cell.
+func (b *board) frontierMask() [][]bool {
+	mask := make([][]bool, b.H)
+	for y := range mask {
+		mask[y] = make([]bool, b.W)
+	}
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if c := b.cells[y][x]; !c.Revealed() || c.Mine {
+				continue
+			}
+			b.around(x, y, func(nx, ny int) {
+				if b.cells[ny][nx].unmarked() {
+					mask[ny][nx] = true
+				}
+			})
+		}
+	}
+	return mask
+}
+
+// Frontier returns the unrevealed, unflagged cells next to a revealed
+// number, in reading order: the cells the visible numbers say something
+// about.
+func (b *board) Frontier() [][2]int {
+	var out [][2]int
+	for y, row := range b.frontierMask() {
+		for x, on := range row {
+			if on {
+				out = append(out, [2]int{x, y})
+			}
+		}
+	}
+	return out
+}
+
+// groupPalette colours constraint groups in turn.
+var groupPalette = []color.Color{
+	rgb(230, 80, 80),
+	rgb(60, 140, 230),
+	rgb(60, 180, 90),
+	rgb(230, 160, 40),
+	rgb(160, 90, 210),
+	rgb(40, 180, 180),
+}
+
+// groupMask numbers every cell by its constraint group, from 1, with 0
+// for cells in none.
+func (b *board) groupMask() [][]int {
+	mask := make([][]int, b.H)
+	for y := range mask {
+		mask[y] = make([]int, b.W)
+	}
+	for gi, group := range b.ConstraintGroups() {
+		for _, i := range group {
+			mask[i/b.W][i%b.W] = gi + 1
+		}
+	}
+	return mask
+}
+
+// findSafeHint picks a cell to open from what the player can see: a cell
+// SolveStep proves safe, or failing that the one MineHeatmap rates least
+// likely to hold a mine. reason says which. With deterministic set ties go
+// to the first cell in reading order (smallest x + y*W) so the same board
+// state always yields the same hint. Only when neither finds a cell does it
+// fall back to reading the mines, with a warning.
+func (b *board) findSafeHint(deterministic bool) (x, y int, reason string, ok bool) {
+	if !b.placed {
+		return b.W / 2, b.H / 2, "Safe cell", true
+	}
+	pick := func(options [][2]int) [2]int {
+		if deterministic {
+			return options[0]
+		}
+		return options[rand.Intn(len(options))]
+	}
+
+	if revealed, _, _ := b.SolveStep(); len(revealed) > 0 {
+		// SolveStep goes by the numbers; sort its cells into reading order
+		sort.Slice(revealed, func(i, j int) bool {
+			return revealed[i][1]*b.W+revealed[i][0] < revealed[j][1]*b.W+revealed[j][0]
+		})
+		p := pick(revealed)
+		return p[0], p[1], "Deduced safe", true
+	}
+
+	heat := b.MineHeatmap()
+	var lowest [][2]int
+	best := 2.0
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if !b.cells[y][x].unmarked() {
+				continue
+			}
+			switch p := heat[y][x]; {
+			case p < best:
+				best = p
+				lowest = append(lowest[:0], [2]int{x, y})
+			case p == best:
+				lowest = append(lowest, [2]int{x, y})
+			}
+		}
+	}
+	if len(lowest) > 0 {
+		p := pick(lowest)
+		return p[0], p[1], fmt.Sprintf("Lowest risk (%.0f%%)", best*100), true
+	}
+
+	var options [][2]int
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if c := b.cells[y][x]; !c.Revealed() && !c.Mine {
+				options = append(options, [2]int{x, y})
+			}
+		}
+	}
+	if len(options) == 0 {
+		return 0, 0, "", false
+	}
+	fmt.Fprintln(os.Stderr, "hint: nothing to go on, picking a safe cell from the layout")
+	p := pick(options)
+	return p[0], p[1], "Safe cell", true
+}
+
+// ComputeProbabilities estimates the chance that each cell holds a mine using
+// only what the player can see. Hidden cells start at the global density of
+// the remaining mines; a hidden cell next to revealed numbers takes the
+// highest local density among those numbers. Revealed cells are 0 and
+// flagged cells 1.
+func (b *board) ComputeProbabilities() [][]float64 {
+	hidden := 0
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if c := b.cells[y][x]; !c.Revealed() && !c.Flagged() {
+				hidden++
+			}
+		}
+	}
+	base := 0.0
+	if hidden > 0 {
+		base = math.Min(1, math.Max(0, float64(b.remainingMines())/float64(hidden)))
+	}
+
+	probs := make([][]float64, b.H)
+	for y := range probs {
+		probs[y] = make([]float64, b.W)
+		for x := range probs[y] {
+			c := b.cells[y][x]
+			switch {
+			case c.Revealed():
+				probs[y][x] = 0
+			case c.Flagged():
+				probs[y][x] = 1
+			default:
+				probs[y][x] = -1
+			}
+		}
+	}
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			if !c.Revealed() || c.Mine || c.Adjacent == 0 {
+				continue
+			}
+			flags := 0
+			var unknown [][2]int
+			b.around(x, y, func(nx, ny int) {
+				nc := b.cells[ny][nx]
+				switch {
+				case nc.Flagged():
+					flags++
+				case !nc.Revealed():
+					unknown = append(unknown, [2]int{nx, ny})
+				}
+			})
+			if len(unknown) == 0 {
+				continue
+			}
+			local := math.Min(1, math.Max(0, float64(c.Adjacent-flags)/float64(len(unknown))))
+			for _, p := range unknown {
+				probs[p[1]][p[0]] = math.Max(probs[p[1]][p[0]], local)
+			}
+		}
+	}
+	for y := range probs {
+		for x := range probs[y] {
+			if probs[y][x] < 0 {
+				probs[y][x] = base
+			}
+		}
+	}
+	return probs
+}
+
+// riskiestCell returns the hidden, unflagged cell with the highest mine
+// probability (first in reading order on ties).
+func (b *board) riskiestCell() (int, int, float64, bool) {
+	probs := b.ComputeProbabilities()
+	bx, by, best, ok := 0, 0, -1.0, false
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			if c.Revealed() || c.Flagged() {
+				continue
+			}
+			if probs[y][x] > best {
+				bx, by, best, ok = x, y, probs[y][x], true
+			}
+		}
+	}
+	return bx, by, best, ok
+}
+
+type point struct{ X, Y int }
+
+type touchStart struct {
+	X, Y         int
+	LastX, LastY int
+	At           time.Time
+	// dragFlagMode is set when the touch moved off its start slowly enough
+	// to be flagging the cells it crosses; dragCell is the last cell it
+	// marked.
+	dragFlagMode bool
+	dragCell     point
+	// swiped is set when the touch left its start too fast to be a drag
+	// flag; it then never becomes one.
+	swiped bool
+	// pinched is set on both touches of a pinch; they neither tap nor
+	// flag.
+	pinched bool
+}
+
+// dragFlagMaxSpeed is the fastest a touch may move, in cells per frame,
+// and still start flagging the cells it crosses.
+const dragFlagMaxSpeed = 2
+
+type theme struct {
+	Name           string
+	BG             color.Color
+	Panel          color.Color
+	Light          color.Color
+	Dark           color.Color
+	CellHidden     color.Color
+	CellRevealed   color.Color
 	CellGrid       color.Color
 	CellText       color.Color
 	Mine           color.Color
@@ -353,6 +1174,11 @@ type theme struct {
 	Digit          color.Color
 	HeaderText     color.Color
 	HeaderTextSoft color.Color
+	// NumberColors is the colour of each count, indexed by Adjacent.
+	NumberColors [9]color.Color
+	// Accessible themes draw a shape behind each number so the count does
+	// not depend on telling colours apart.
+	Accessible bool
 }
 
 var themes = []theme{
@@ -374,6 +1200,17 @@ var themes = []theme{
 		Digit:          rgb(215, 40, 40),
 		HeaderText:     rgb(12, 12, 12),
 		HeaderTextSoft: rgb(30, 30, 30),
+		NumberColors: [9]color.Color{
+			color.RGBA{},
+			rgb(25, 25, 220),
+			rgb(0, 130, 0),
+			rgb(210, 20, 20),
+			rgb(0, 0, 135),
+			rgb(130, 0, 0),
+			rgb(0, 128, 128),
+			rgb(0, 0, 0),
+			rgb(110, 110, 110),
+		},
 	},
 	{
 		Name:           "Dark",
@@ -393,24 +1230,128 @@ var themes = []theme{
 		Digit:          rgb(255, 98, 98),
 		HeaderText:     rgb(245, 245, 245),
 		HeaderTextSoft: rgb(215, 215, 225),
+		// the Classic palette with a 1 light enough for the dark cells
+		NumberColors: [9]color.Color{
+			color.RGBA{},
+			rgb(120, 170, 255),
+			rgb(0, 130, 0),
+			rgb(210, 20, 20),
+			rgb(0, 0, 135),
+			rgb(130, 0, 0),
+			rgb(0, 128, 128),
+			rgb(0, 0, 0),
+			rgb(110, 110, 110),
+		},
+	},
+	{
+		// blue/orange/black only, which stays distinct under protanopia and
+		// deuteranopia
+		Name:           "Colorblind",
+		BG:             rgb(232, 232, 232),
+		Panel:          rgb(232, 232, 232),
+		Light:          rgb(255, 255, 255),
+		Dark:           rgb(105, 105, 105),
+		CellHidden:     rgb(196, 196, 196),
+		CellRevealed:   rgb(250, 250, 250),
+		CellGrid:       rgb(160, 160, 160),
+		CellText:       rgb(0, 0, 0),
+		Mine:           rgb(0, 0, 0),
+		Flag:           rgb(0, 114, 178),
+		WrongFlag:      rgb(230, 159, 0),
+		Accent:         rgb(0, 114, 178),
+		Overlay:        color.RGBA{0, 0, 0, 120},
+		Digit:          rgb(230, 159, 0),
+		HeaderText:     rgb(0, 0, 0),
+		HeaderTextSoft: rgb(40, 40, 40),
+		// blue, orange, black in turn so neighbouring counts never share a
+		// colour; the shapes carry the actual distinction
+		NumberColors: [9]color.Color{
+			color.RGBA{},
+			rgb(0, 114, 178),
+			rgb(213, 94, 0),
+			rgb(0, 0, 0),
+			rgb(0, 114, 178),
+			rgb(213, 94, 0),
+			rgb(0, 0, 0),
+			rgb(0, 114, 178),
+			rgb(213, 94, 0),
+		},
+		Accessible: true,
 	},
 }
 
-var numberColors = []color.Color{
-	color.RGBA{},
-	rgb(25, 25, 220),
-	rgb(0, 130, 0),
-	rgb(210, 20, 20),
-	rgb(0, 0, 135),
-	rgb(130, 0, 0),
-	rgb(0, 128, 128),
-	rgb(0, 0, 0),
-	rgb(110, 110, 110),
+// customFields is the number of fields the custom dialog cycles through.
+const customFields = 6
+
+// Largest board the custom dialog allows, and so the largest a save file
+// may hold.
+const (
+	maxBoardW = 60
+	maxBoardH = 32
+)
+
+// The first click's safe zone reaches defaultSafePadding cells out unless
+// --safe-zone or the custom dialog says otherwise.
+const (
+	defaultSafePadding = 1
+	maxSafePadding     = 2
+)
+
+// safeZone is the --safe-zone flag, the SafeZone new games start with.
+var safeZone = defaultSafePadding
+
+// safeZoneLabel names a safe padding by the block it keeps clear.
+func safeZoneLabel(p int) string {
+	if p <= 0 {
+		return "none"
+	}
+	side := 2*p + 1
+	return fmt.Sprintf("%dx%d", side, side)
 }
 
 type customConfig struct {
 	W, H, Mines int
 	field       int
+	// Connectivity is the cascade mode for the next custom game.
+	Connectivity int
+	// Seed is used for the next custom game when hasSeed is set.
+	Seed      int64
+	hasSeed   bool
+	seedInput numberInput
+}
+
+// numberInput is a minimal digit-only text field.
+type numberInput struct {
+	active bool
+	buf    string
+}
+
+func (in *numberInput) open() {
+	in.active = true
+	in.buf = ""
+}
+
+// update consumes this frame's keyboard input and reports the value once
+// Enter confirms it. Esc closes the field without a value.
+func (in *numberInput) update() (int64, bool) {
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if r >= '0' && r <= '9' && len(in.buf) < 18 {
+			in.buf += string(r)
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(in.buf) > 0 {
+		in.buf = in.buf[:len(in.buf)-1]
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		in.active = false
+		return 0, false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		in.active = false
+		v, err := strconv.ParseInt(in.buf, 10, 64)
+		return v, err == nil
+	}
+	return 0, false
 }
 
 type game struct {
@@ -419,97 +1360,742 @@ type game struct {
 	diff           difficulty
 	themeIdx       int
 	allowQuestion  bool
-	showHelp       bool
-	showScores     bool
-	showCustom     bool
+	ChordThreshold int
+	// HintDeterministic makes H always point at the same cell for a given
+	// board state, which keeps tests and replays reproducible.
+	HintDeterministic bool
+	showHelp          bool
+	showScores        bool
+	showCustom        bool
+	showCoordinates   bool
+	showCorroborated  bool
+	// showChordable tints open numbers whose flags are all placed, where a
+	// chord would open the rest.
+	showChordable bool
+	// showFrontier outlines the frontier; frontier is the mask drawn this
+	// frame, nil while it is off.
+	showFrontier bool
+	frontier     [][]bool
+	// showGroups tints linked frontier cells alike; groupOf is each cell's
+	// group this frame, counted from 1 with 0 for none.
+	showGroups bool
+	groupOf    [][]int
+	// showProbability prints each hidden cell's mine chance; heatmap is
+	// this frame's MineHeatmap, nil while it is off.
+	showProbability bool
+	heatmap         [][]float64
+	retryRect       image.Rectangle
+	hintUsed        bool
+	layoutHinted    bool
+	// hintsRemaining is how many more hints H gives this game; -1 is no
+	// limit.
+	hintsRemaining int
+	// view scrolls boards that do not fit the window.
+	view viewport
+	// fullscreen draws into frame, centred on the screen; windowed is the
+	// window to return to.
+	fullscreen bool
+	windowed   windowedState
+	frame      *ebiten.Image
+	// exitWindow is the window as it was when the game was closed.
+	exitWindow *windowState
+	// bv3 is the 3BV of the layout in play, worked out when the first move
+	// starts the clock.
+	bv3 int
+	// leftClicks, rightClicks and chordClicks count mouse, touch and
+	// keyboard moves made on the board this game.
+	leftClicks  int
+	rightClicks int
+	chordClicks int
+	// assisted is set once the solver has played moves for the player; such
+	// a game does not set records.
+	assisted bool
+	// practiceMode lifts the undo limit and keeps games out of the records.
+	// practicePending is the outcome of a practice game waiting for the
+	// player to accept it or undo; statePlaying when there is none.
+	practiceMode    bool
+	practicePending gameState
+	// efficiencyMode scores wins by click count instead of time.
+	efficiencyMode bool
+	// flagPenaltyMode adds flagPenalty per wrong flag to a win;
+	// flagPenaltyTime is what the last win got.
+	flagPenaltyMode bool
+	flagPenaltyTime time.Duration
+	// noFlags is the variant without flags or question marks: mines are
+	// kept in mind and the counter shows the ones not yet deduced.
+	noFlags bool
+	// marathonMode chains marathonBoards boards; marathonScore is the
+	// total so far in milliseconds, wins adding their time and losses
+	// marathonPenalty. marathonNext starts the next board on the next
+	// frame; showMarathon is the summary at the end.
+	marathonMode    bool
+	marathonScore   int
+	marathonResults []marathonResult
+	marathonNext    bool
+	// tournament is the Ctrl+G run in progress; tournamentNext starts its
+	// next round on the next frame and showTournament is its summary.
+	tournament     *Tournament
+	tournamentNext bool
+	showTournament bool
+	showMarathon   bool
+	// showSettings is the Ctrl+O panel; settingsIdx is its focused row.
+	showSettings bool
+	settingsIdx  int
+	// soundEnabled is kept for the settings panel; nothing plays sounds
+	// yet.
+	soundEnabled bool
+	// editorMode is the Ctrl+E board editor; editorPrev is the game it
+	// replaced, restored if the editor is cancelled.
+	editorMode bool
+	editorPrev *board
+	// whatIf is the Ctrl+W trial mode: whatIfSnap is the board to roll
+	// back to and whatIfCells the cells opened on trial.
+	whatIf      bool
+	whatIfSnap  boardSnapshot
+	whatIfCells map[[2]int]bool
+	// contextMenu is the open right-click menu, if any; useContextMenu
+	// makes a right click open it instead of marking the cell.
+	contextMenu    *ContextMenu
+	useContextMenu bool
+	toast          string
+	toastUntil     time.Time
 	custom         customConfig
+	cursorBlink    bool
+	lastBlink      time.Time
 	hint           *point
-	timerStart     time.Time
-	pauseStarted   time.Time
-	paused         bool
-	elapsedSeconds int
-	bestScores     map[string]int
-	faceRect       image.Rectangle
-	touchModeRect  image.Rectangle
-	touchFlagMode  bool
-	fontMain       font.Face
-	touchStarts    map[ebiten.TouchID]touchStart
+	// antiHint marks the riskiest cell when AntiHintMode is on.
+	antiHint   *point
+	hintReason string
+	// explain holds the deduction walk-through shown with Shift+E after a
+	// win; explainIdx is the step currently highlighted.
+	explain     []DeductionStep
+	explainIdx  int
+	showExplain bool
+	// placing delivers the layout for the first click, which is built off
+	// the UI goroutine; pendingReveal is that click.
+	placing       <-chan *board
+	cancelPlacing context.CancelFunc
+	pendingReveal point
+	// eventLog lists every action of the current game, for analysis
+	// outside the game; see writeEventLog.
+	eventLog []GameEvent
+	// replayEvents records the moves of the current game. While playback
+	// is set, a saved replay drives the board instead of the player.
+	replayEvents []ReplayEvent
+	showReplays  bool
+	replayList   []*Replay
+	replayIdx    int
+	playback     *Replay
+	playIdx      int
+	playClock    time.Duration
+	playPaused   bool
+	playSpeed    int
+	// the player's own settings, put back when a playback ends
+	savedChord     int
+	savedQuestion  bool
+	savedCountdown int
+	AntiHintMode   bool
+	// QuickFlagMode swaps the primary and secondary actions so left click
+	// (and tap) flags while right click (and long press) reveals.
+	// endedQuickFlag is what it was when the game ended, for the banner.
+	QuickFlagMode  bool
+	endedQuickFlag bool
+	// leftHanded swaps the left and right mouse buttons.
+	leftHanded bool
+	// CountdownSec, when non-zero, is a time limit: the timer counts down
+	// from it and the game is lost when it runs out.
+	CountdownSec int
+	// NoGuess makes new layouts solvable without guessing (see
+	// board.Solvable) and turns hints off, since nothing needs one.
+	NoGuess bool
+	// Toroidal makes new layouts wrap around their edges (see
+	// board.Toroidal).
+	Toroidal bool
+	// Symmetric makes new layouts point-symmetric (see
+	// board.SymmetricMines).
+	Symmetric bool
+	// Clustered makes new layouts clump their mines (see
+	// board.ClusteredMines).
+	Clustered bool
+	// SafeZone is the safe padding around the first click for new layouts
+	// (see board.safePadding).
+	SafeZone     int
+	timerStart   time.Time
+	pauseStarted time.Time
+	paused       bool
+	// autopaused is set when the pause came from the window losing focus,
+	// so only that kind of pause is lifted when focus comes back.
+	autopaused bool
+	// AutoPauseOnFocusLoss pauses a running game while the window is in
+	// the background.
+	AutoPauseOnFocusLoss bool
+	elapsed              time.Duration
+	// wrongFlags counts the safe cells that have had a flag this game;
+	// wrongFlagCells holds them, so flagging one again does not count.
+	wrongFlags     int
+	wrongFlagCells map[[2]int]bool
+	// wrongFlagsOnLoss is how many flags stood on safe cells when the game
+	// was lost.
+	wrongFlagsOnLoss int
+	// displayedProgress glides towards targetProgress (the revealed share of
+	// safe cells) so the progress bar animates.
+	displayedProgress float64
+	targetProgress    float64
+	progressPulse     int
+	lastScore         float64
+	// paceNote compares the last win's pace with the player's average,
+	// for the win banner.
+	paceNote   string
+	bestScores map[string]ScoreEntry
+	stats      map[string]*Stats
+	faceRect   image.Rectangle
+	// swatchRects are the theme swatches in the scores overlay, in theme
+	// order, as last drawn.
+	swatchRects   []image.Rectangle
+	touchModeRect image.Rectangle
+	touchFlagMode bool
+	fontMain      font.Face
+	touchStarts   map[ebiten.TouchID]touchStart
+	// pinchStartDist is the distance between the two fingers when the
+	// current pinch began, 0 when there is none; pinchStartCell is the cell
+	// size at that moment.
+	pinchStartDist float64
+	pinchStartCell int
+
+	// nextBoard is generated in the background while the post-game overlay
+	// is up, so starting the next game does not have to wait for it.
+	nextMu    sync.Mutex
+	nextBoard *board
+
+	events EventBus
+	// telemetry is nil with --no-telemetry.
+	telemetry *Telemetry
+
+	// cursor is the keyboard cursor. It stays hidden until an arrow key or
+	// Tab first moves it, so mouse players never see it.
+	cursor      point
+	cursorShown bool
+
+	// revealQueue holds the cells of a cascade still to be opened, a few per
+	// frame; the board ignores input until it drains. fading counts the
+	// frames since each opened cell started fading in, below zero while it
+	// waits its turn. animationsEnabled turns the fade off.
+	revealQueue       [][2]int
+	fading            map[[2]int]int
+	animationsEnabled bool
+	// particles are the sparks of the last explosion. shakeFrames counts
+	// down the board shake after it; shake is the offset of the frame
+	// being drawn.
+	particles   []Particle
+	shakeFrames int
+	shake       point
+	// hintPulse runs from 0 to 1 and wraps while a hint is shown; the hint
+	// outline swells and fades with it.
+	hintPulse float32
+	// zoomLevel scales the drawn board (0 means 1). At any other zoom the
+	// board is drawn unscaled into zoomImg, with zoomOff its scroll
+	// position, and zoomDrawing set meanwhile.
+	zoomLevel   float64
+	zoomImg     *ebiten.Image
+	zoomOff     point
+	zoomDrawing bool
+	// confetti falls after a win until confettiUntil; confettiDone keeps
+	// it to once per game.
+	confetti       []ConfettiParticle
+	confettiUntil  time.Time
+	confettiDone   bool
+	RevealPerFrame int
+	// FastReveal opens cascades of more than fastRevealThreshold cells at
+	// once instead of animating them.
+	FastReveal bool
+
+	// undo holds the board before each recent move, newest last.
+	undo      []boardSnapshot
+	UndoDepth int
+
+	// savedUntil keeps the floppy on the face button briefly after Ctrl+S.
+	savedUntil time.Time
+
+	// seedPrompt is the Shift+N field for starting a game from a seed.
+	seedPrompt numberInput
+
+	// headless is set for RunHeadless; finished games then leave no
+	// records, replays or logs behind.
+	headless bool
+
+	// inspected is the cell last Alt+clicked in a debug build.
+	inspected *point
+
+	// quit is set from the signal goroutine; Update then ends the game loop.
+	quit atomic.Bool
 }
 
 func newGame() *game {
+	themes = mergeThemes(themes, loadThemes())
 	g := &game{
 		diff:          presets[0],
 		themeIdx:      0,
 		allowQuestion: true,
 		fontMain:      basicfont.Face7x13,
 		bestScores:    loadScores(),
+		stats:         loadStats(),
 		touchStarts:   map[ebiten.TouchID]touchStart{},
+
+		AutoPauseOnFocusLoss: true,
+		UndoDepth:            defaultUndoDepth,
+		RevealPerFrame:       defaultRevealPerFrame,
+		animationsEnabled:    true,
+		SafeZone:             safeZone,
 	}
 	g.b = newBoard(g.diff.W, g.diff.H, g.diff.Mines)
 	g.custom = customConfig{W: 24, H: 20, Mines: 99, field: 0}
+	g.subscribeReplay()
+	g.subscribeEffects()
 	g.reset(false)
 	g.resizeWindow()
 	return g
 }
 
 func (g *game) reset(changeDiff bool) {
-	if changeDiff {
+	// a daily challenge is a single attempt: starting over leaves it
+	if g.diff.Daily != "" && !changeDiff {
+		g.diff = presets[2]
+		changeDiff = true
+	}
+	if g.b.retainLayout && !changeDiff {
+		g.layoutHinted = g.layoutHinted || g.hintUsed
+	} else {
+		g.layoutHinted = false
+	}
+	g.hintUsed = false
+	g.hintsRemaining = hintsFor(g.diff)
+	g.assisted = false
+	g.practicePending = statePlaying
+	g.bv3 = 0
+	g.leftClicks, g.rightClicks, g.chordClicks = 0, 0, 0
+	g.eventLog = nil
+	g.cancelPlacement()
+	switch {
+	case changeDiff:
+		g.b.ConnectivityMode = g.diff.Connectivity
+		g.b.safePadding = g.SafeZone
 		g.b.configure(g.diff.W, g.diff.H, g.diff.Mines)
 		g.resizeWindow()
-	} else {
+	case g.diff.Puzzle != "":
+		// a puzzle has no other layout to move on to
+		g.b.resetKeepMines()
+	case g.b.retainLayout:
 		g.b.reset()
+	default:
+		if next := g.takeNextBoard(); next != nil {
+			g.b = next
+		} else {
+			g.b.reset()
+		}
+	}
+	g.b.ClearLocks()
+	if !g.b.placed {
+		g.b.Solvable = g.NoGuess
+		g.b.Toroidal = g.Toroidal
+		g.b.SymmetricMines = g.Symmetric
+		g.b.ClusteredMines = g.Clustered
+		g.b.safePadding = g.SafeZone
+		g.b.Mines = g.layoutMineCount()
 	}
 	g.state = statePlaying
 	g.timerStart = time.Time{}
 	g.pauseStarted = time.Time{}
 	g.paused = false
-	g.elapsedSeconds = 0
-	g.hint = nil
+	g.autopaused = false
+	g.elapsed = 0
+	g.wrongFlags = 0
+	g.wrongFlagCells = nil
+	g.wrongFlagsOnLoss = 0
+	g.endedQuickFlag = false
+	g.flagPenaltyTime = 0
+	g.particles = nil
+	g.shakeFrames = 0
+	g.confetti = nil
+	g.confettiDone = false
+	g.displayedProgress = 0
+	g.progressPulse = 0
+	g.lastScore = 0
+	g.paceNote = ""
+	g.clearHints()
+	g.closeExplain()
+	g.undo = nil
+	g.revealQueue = nil
+	g.fading = nil
+	g.replayEvents = nil
+	g.seedTournamentRound()
+}
+
+// retry restarts the current mine layout with the timer and marks cleared.
+func (g *game) retry() {
+	if !g.b.placed {
+		g.reset(false)
+		return
+	}
+	g.b.retainLayout = true
+	g.reset(false)
+	g.b.retainLayout = false
+}
+
+// startSeeded starts a new game whose mines come from seed, so the same
+// seed and first click always give the same layout.
+func (g *game) startSeeded(seed int64) {
+	g.reset(false)
+	if g.b.pregenerated {
+		g.b.clearMines()
+	}
+	g.b.SetSeed(seed)
 }
 
 func (g *game) resizeWindow() {
-	w, h := g.Layout(0, 0)
+	// size the window for the new board, not the one the player left
+	g.view.outsideW, g.view.outsideH = 0, 0
+	g.view.offX, g.view.offY = 0, 0
+	w, h := g.viewSize()
 	ebiten.SetWindowSize(w, h)
-	ebiten.SetWindowTitle(fmt.Sprintf("Go Minesweeper - %s", g.diff.Name))
+	title := g.diff.Name
+	if g.diff.Daily != "" {
+		title = "Daily " + g.diff.Daily
+	}
+	ebiten.SetWindowTitle(fmt.Sprintf("Go Minesweeper - %s", title))
 }
 
-func (g *game) Layout(_, _ int) (int, int) {
-	return g.b.W*cellSize + outerPadding*2, topPanelHeight + g.b.H*cellSize + outerPadding*2
+// setCellSize changes the cell size, clamped to [minCellSize, maxCellSize],
+// and resizes the window to match.
+func (g *game) setCellSize(px int) {
+	cellSizePx = clamp(px, minCellSize, maxCellSize)
+	g.resizeWindow()
+}
+
+// Layout fits the whole board when the screen allows; a bigger board is
+// shown through a scrolling viewport. Callers pass 0, 0 to just read the
+// current size.
+func (g *game) Layout(outsideW, outsideH int) (int, int) {
+	if outsideW > 0 && outsideH > 0 {
+		g.view.outsideW, g.view.outsideH = outsideW, outsideH
+		if g.fullscreen {
+			// the game is centred inside the whole screen; see Draw
+			return outsideW, outsideH
+		}
+	}
+	return g.viewSize()
+}
+
+// prepareNextBoard starts generating the board for the next game.
+func (g *game) prepareNextBoard() {
+	nb := g.b.blank()
+	nb.Solvable = g.NoGuess
+	nb.Toroidal = g.Toroidal
+	nb.SymmetricMines = g.Symmetric
+	nb.ClusteredMines = g.Clustered
+	nb.safePadding = g.SafeZone
+	go func() {
+		nb.placeMines(nb.W/2, nb.H/2)
+		nb.pregenerated = true
+		g.nextMu.Lock()
+		g.nextBoard = nb
+		g.nextMu.Unlock()
+	}()
+}
+
+// takeNextBoard returns the pre-generated board if it is ready and still
+// matches the current configuration.
+func (g *game) takeNextBoard() *board {
+	g.nextMu.Lock()
+	nb := g.nextBoard
+	g.nextBoard = nil
+	g.nextMu.Unlock()
+	if nb == nil || nb.W != g.b.W || nb.H != g.b.H || nb.Mines != g.layoutMineCount() || nb.Solvable != g.NoGuess || nb.Toroidal != g.Toroidal || nb.SymmetricMines != g.Symmetric || nb.ClusteredMines != g.Clustered || nb.safePadding != g.SafeZone {
+		return nil
+	}
+	return nb
+}
+
+// layoutMineCount is the mine count of the next layout: the difficulty's,
+// taken back from any rounding a symmetric layout did, and rounded down to
+// even again if the next one is symmetric.
+func (g *game) layoutMineCount() int {
+	m := clamp(g.diff.Mines, 1, g.b.maxMines())
+	if g.Symmetric && m > 1 {
+		m &^= 1
+	}
+	return m
+}
+
+// toggleSymmetric switches symmetric layouts on or off. A board still
+// waiting for its first click takes the change, mine count included.
+func (g *game) toggleSymmetric() {
+	g.Symmetric = !g.Symmetric
+	if !g.b.placed {
+		g.b.SymmetricMines = g.Symmetric
+		g.b.Mines = g.layoutMineCount()
+	}
+	g.showToast(fmt.Sprintf("Symmetric mines: %v (from the next layout)", g.Symmetric))
 }
 
 func (g *game) setDifficulty(d difficulty) {
 	g.diff = d
 	g.reset(true)
+	g.events.Publish(DifficultyChangedEvent{Name: d.Name})
 }
 
 func (g *game) onGameWon() {
+	if g.holdOutcome(stateWon) {
+		return
+	}
 	g.state = stateWon
-	g.b.autoFlagMines()
+	g.endedQuickFlag = g.QuickFlagMode
+	g.progressPulse = 60
+	if !g.noFlags {
+		g.b.autoFlagMines()
+	}
 	if !g.timerStart.IsZero() {
-		elapsed := g.elapsedSeconds
-		if elapsed <= 0 {
-			elapsed = 1
+		// Update only samples the clock once a frame
+		g.elapsed = time.Since(g.timerStart)
+	}
+	g.events.Publish(GameWonEvent{Elapsed: g.elapsed})
+	if g.playback != nil || g.headless {
+		return
+	}
+	g.applyFlagPenalty()
+	g.saveReplay(true)
+	g.writeEventLog(true)
+	key := g.scoreKey()
+	// a layout that was replayed after taking hints is no longer a fair record
+	fair := !g.layoutHinted && !g.assisted && !g.practiceMode
+	if !g.timerStart.IsZero() && !g.practiceMode {
+		st := g.statsFor(key)
+		st.recordWin(g.elapsed, fair)
+		st.recordClicks(g.leftClicks, g.rightClicks, g.chordClicks)
+		st.recordFlagAccuracy(g.wrongFlags, g.elapsed, fair)
+		g.paceNote = paceNote(st, g.bv3PerSecond())
+		st.recordPace(g.bv3PerSecond())
+		if g.diff.Daily != "" && st.DailyDate == g.diff.Daily {
+			st.DailyWon = true
+			st.DailyTimeMs = g.elapsed.Milliseconds()
 		}
-		key := g.scoreKey()
+	}
+	if !g.timerStart.IsZero() && fair && g.efficiencyMode {
+		if g.recordEfficiency(key, max(g.elapsed, minRecordTime)) {
+			saveScores(g.bestScores)
+		}
+	} else if !g.timerStart.IsZero() && fair {
+		elapsed := max(g.elapsed, minRecordTime)
 		best, ok := g.bestScores[key]
-		if !ok || best == 0 || elapsed < best {
-			g.bestScores[key] = elapsed
+		if !ok || best.DurationNs == 0 || elapsed < best.Duration() {
+			g.bestScores[key] = ScoreEntry{
+				DurationNs:          int64(elapsed),
+				Seed:                g.b.seed,
+				Mark:                g.scoreMark(),
+				NormalisedTimeScore: normalisedTime(elapsed.Seconds(), g.b.W, g.b.H, g.b.Mines),
+			}
 			saveScores(g.bestScores)
 		}
+
+		g.lastScore = g.computeWeightedScore()
+		st := g.statsFor(key)
+		st.BestWeightedScore = math.Max(st.BestWeightedScore, g.lastScore)
+		st.Best3BVPerSec = math.Max(st.Best3BVPerSec, g.bv3PerSecond())
 	}
+	saveStats(g.stats)
+	g.marathonFinish(true)
+	g.tournamentFinish(true)
+	g.prepareNextBoard()
+}
+
+func (g *game) onGameLost() {
+	if g.holdOutcome(stateLost) {
+		return
+	}
+	g.state = stateLost
+	g.endedQuickFlag = g.QuickFlagMode
+	g.wrongFlagsOnLoss = g.b.revealAllMines()
+	g.events.Publish(GameLostEvent{Elapsed: g.elapsed})
+	if g.playback != nil || g.headless {
+		return
+	}
+	g.saveReplay(false)
+	g.writeEventLog(false)
+	if !g.practiceMode {
+		st := g.statsFor(g.scoreKey())
+		st.recordLoss()
+		st.recordClicks(g.leftClicks, g.rightClicks, g.chordClicks)
+		st.WrongFlags += g.wrongFlags
+		saveStats(g.stats)
+	}
+	g.marathonFinish(false)
+	g.tournamentFinish(false)
+	g.prepareNextBoard()
+}
+
+// clickEfficiency is the share of revealing clicks that were needed: 3BV
+// over left clicks plus chords, capped at 1. It is 0 before any click.
+func (g *game) clickEfficiency() float64 {
+	clicks := g.leftClicks + g.chordClicks
+	if clicks == 0 {
+		return 0
+	}
+	return math.Min(float64(g.bv3)/float64(clicks), 1)
+}
+
+// bv3PerSecond is the clearing speed of the current game.
+func (g *game) bv3PerSecond() float64 {
+	return float64(g.bv3) / max(g.elapsed, minRecordTime).Seconds()
+}
+
+// noteWrongFlag counts a flag put on the safe cell (x,y), once per cell:
+// taking the flag off, or undoing it, and flagging the cell again is still
+// the one mistake.
+func (g *game) noteWrongFlag(x, y int) {
+	p := [2]int{x, y}
+	if g.wrongFlagCells[p] {
+		return
+	}
+	if g.wrongFlagCells == nil {
+		g.wrongFlagCells = map[[2]int]bool{}
+	}
+	g.wrongFlagCells[p] = true
+	g.wrongFlags++
+}
+
+// computeWeightedScore rewards board complexity (3BV) and penalises both the
+// time taken and every flag that was placed on a safe cell during the game.
+// In penalty mode the wrong flags are already in the time.
+func (g *game) computeWeightedScore() float64 {
+	elapsed := math.Max(g.elapsed.Seconds(), 1)
+	penalty := float64(g.wrongFlags * 10)
+	if g.flagPenaltyMode {
+		penalty = 0
+	}
+	return float64(g.b.Compute3BV()*1000) / (elapsed + penalty)
+}
+
+// Score key modifiers. Each one that applies to a game is appended to the
+// base key, so records from different variants never mix.
+const (
+	ModFourConn    = "4conn"
+	ModQuickFlag   = "quickflag"
+	ModToroidal    = "toroidal"
+	ModNoGuess     = "noguess"
+	ModAssisted    = "assisted"
+	ModEfficiency  = "eff"
+	ModFlagPenalty = "flagpenalty"
+	ModNoFlags     = "noflags"
+	ModSymmetric   = "sym"
+	ModClustered   = "cluster"
+	// ModSafeZone is followed by the padding, for any but the default.
+	ModSafeZone = "safe"
+)
+
+// ScoreKeyBuilder assembles a score key as NAME_WxH_MINES followed by any
+// modifiers, joined with underscores. A Prefix, when set, goes first.
+type ScoreKeyBuilder struct {
+	Prefix               string
+	Difficulty           string
+	Width, Height, Mines int
+	modifiers            []string
+}
+
+// With adds a modifier when on is true. Modifiers keep the order they are
+// added in.
+func (k *ScoreKeyBuilder) With(mod string, on bool) *ScoreKeyBuilder {
+	if on {
+		k.modifiers = append(k.modifiers, mod)
+	}
+	return k
+}
+
+func (k *ScoreKeyBuilder) Build() string {
+	parts := append([]string{k.Difficulty, fmt.Sprintf("%dx%d", k.Width, k.Height), strconv.Itoa(k.Mines)}, k.modifiers...)
+	if k.Prefix != "" {
+		parts = append([]string{k.Prefix}, parts...)
+	}
+	return strings.Join(parts, "_")
 }
 
 func (g *game) scoreKey() string {
-	return fmt.Sprintf("%s_%dx%d_%d", g.diff.Name, g.diff.W, g.diff.H, g.diff.Mines)
+	k := &ScoreKeyBuilder{Difficulty: g.diff.Name, Width: g.diff.W, Height: g.diff.H, Mines: g.diff.Mines}
+	switch {
+	case g.diff.Daily != "":
+		k.Prefix = dailyPrefix
+	case g.CountdownSec > 0:
+		k.Prefix = countdownPrefix
+	}
+	return k.With(fmt.Sprintf("%ds", g.CountdownSec), g.CountdownSec > 0).
+		With(ModFourConn, g.diff.Connectivity == Connectivity4).
+		With(ModQuickFlag, g.QuickFlagMode).
+		With(ModToroidal, g.b.Toroidal).
+		With(ModNoGuess, g.b.Solvable).
+		With(ModEfficiency, g.efficiencyMode).
+		With(ModFlagPenalty, g.flagPenaltyMode).
+		With(ModNoFlags, g.noFlags).
+		With(ModAssisted, g.assisted).
+		With(ModSymmetric, g.b.SymmetricMines).
+		With(ModClustered, g.b.ClusteredMines).
+		With(ModSafeZone+strconv.Itoa(g.b.safePadding), g.b.safePadding != defaultSafePadding && g.diff.Puzzle == "").
+		Build()
+}
+
+// scoreSortKey ranks a score key by its difficulty so the records list
+// reads Beginner, Intermediate, Expert, Custom, then daily challenges.
+func scoreSortKey(s string) int {
+	if strings.HasPrefix(s, dailyPrefix+"_") {
+		return len(presets) + 2
+	}
+	if strings.HasPrefix(s, countdownPrefix+"_") {
+		return len(presets) + 1
+	}
+	name, _, _ := strings.Cut(s, "_")
+	for i, d := range presets {
+		if d.Name == name {
+			return i
+		}
+	}
+	return len(presets)
+}
+
+// scoreKeyArea returns W*H from the "NAME_WxH_mines" score key, or 0 when
+// the key does not have that shape.
+func scoreKeyArea(s string) int {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, dailyPrefix+"_"), countdownPrefix+"_")
+	parts := strings.Split(s, "_")
+	if len(parts) < 2 {
+		return 0
+	}
+	var w, h int
+	if _, err := fmt.Sscanf(parts[1], "%dx%d", &w, &h); err != nil {
+		return 0
+	}
+	return w * h
+}
+
+// sortScoreKeys orders keys by scoreSortKey, then by board area, then by
+// name.
+func sortScoreKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := scoreSortKey(keys[i]), scoreSortKey(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ai, aj := scoreKeyArea(keys[i]), scoreKeyArea(keys[j]); ai != aj {
+			return ai < aj
+		}
+		return keys[i] < keys[j]
+	})
 }
 
 func (g *game) boardPosFromCursor(mx, my int) (int, int, bool) {
-	bx0, by0 := outerPadding, topPanelHeight
-	if mx < bx0 || my < by0 {
+	r := g.boardRect()
+	if !pointInRect(mx, my, r) {
 		return 0, 0, false
 	}
-	x := (mx - bx0) / cellSize
-	y := (my - by0) / cellSize
+	span := g.cellSpan()
+	x := int(float64(mx-r.Min.X+g.view.offX) / span)
+	y := int(float64(my-r.Min.Y+g.view.offY) / span)
 	if !g.b.in(x, y) {
 		return 0, 0, false
 	}
@@ -517,6 +2103,8 @@ func (g *game) boardPosFromCursor(mx, my int) (int, int, bool) {
 }
 
 func (g *game) normalizeInputPos(x, y int) (int, int) {
+	ox, oy := g.letterboxOffset()
+	x, y = x-ox, y-oy
 	lw, lh := g.Layout(0, 0)
 	if x >= 0 && y >= 0 && x < lw && y < lh {
 		return x, y
@@ -547,55 +2135,283 @@ func (g *game) handleRevealAt(mx, my int) bool {
 		g.reset(false)
 		return true
 	}
+	if g.state != statePlaying && pointInRect(mx, my, g.retryRect) {
+		g.retry()
+		return true
+	}
 
 	if g.showHelp {
 		g.showHelp = false
 		return true
 	}
 	if g.showScores {
+		for i, r := range g.swatchRects {
+			if pointInRect(mx, my, r) {
+				g.themeIdx = i
+				return true
+			}
+		}
 		g.showScores = false
 		return true
 	}
 
-	if g.paused || g.state != statePlaying {
+	if g.paused || g.state != statePlaying {
+		return false
+	}
+
+	x, y, ok := g.boardPosFromCursor(mx, my)
+	if !ok {
+		return false
+	}
+	if g.b.cells[y][x].Revealed() {
+		g.chordClicks++
+	} else {
+		g.leftClicks++
+	}
+	return g.revealCell(x, y)
+}
+
+// revealCell opens or chords (x,y). The first reveal of a fresh board only
+// starts mine placement; pollPlacement finishes it once the layout is in.
+func (g *game) revealCell(x, y int) bool {
+	if g.placing != nil {
+		return false
+	}
+	if c := g.b.cells[y][x]; !g.b.placed && !c.Flagged() && !c.Locked {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.placing = g.b.placeMinesAsync(ctx, x, y)
+		g.cancelPlacing = cancel
+		g.pendingReveal = point{X: x, Y: y}
+		return true
+	}
+
+	before := g.b.snapshot()
+	if g.b.cells[y][x].Revealed() {
+		hit, changed := g.b.chord(x, y, g.ChordThreshold)
+		return g.finishMove(ReplayChord, x, y, before, hit, changed)
+	}
+
+	hit, cells := g.b.planReveal(x, y)
+	if hit || len(cells) == 0 {
+		return g.finishMove(ReplayReveal, x, y, before, hit, hit)
+	}
+	// the clicked cell opens now; the rest of the cascade drips in
+	g.b.revealOne(x, y)
+	g.startFade([2]int{x, y}, 0)
+	rest := cells[1:]
+	if g.FastReveal && len(cells) > fastRevealThreshold {
+		for _, p := range rest {
+			g.b.revealOne(p[0], p[1])
+			// cells further from the click fade in later, the way the
+			// cascade spreads
+			g.startFade(p, max(absInt(p[0]-x), absInt(p[1]-y))*revealFadeStagger)
+		}
+		rest = nil
+	}
+	g.revealQueue = rest
+	return g.finishMove(ReplayReveal, x, y, before, false, true)
+}
+
+// stepRevealQueue opens the next RevealPerFrame cells of a cascade and ages
+// the fade-in of cells opened earlier.
+func (g *game) stepRevealQueue() {
+	for p, f := range g.fading {
+		if f+1 >= revealFadeFrames {
+			delete(g.fading, p)
+		} else {
+			g.fading[p] = f + 1
+		}
+	}
+	if len(g.revealQueue) == 0 || g.paused || g.state != statePlaying {
+		return
+	}
+	n := min(max(g.RevealPerFrame, 1), len(g.revealQueue))
+	for _, p := range g.revealQueue[:n] {
+		if g.b.revealOne(p[0], p[1]) {
+			g.startFade(p, 0)
+		}
+	}
+	g.revealQueue = g.revealQueue[n:]
+	if g.b.isWin() {
+		g.revealQueue = nil
+		g.onGameWon()
+	}
+}
+
+// chordCell chords (x,y) and does nothing on a cell that is not revealed.
+func (g *game) chordCell(x, y int) bool {
+	if g.placing != nil || !g.b.cells[y][x].Revealed() {
+		return false
+	}
+	before := g.b.snapshot()
+	hit, changed := g.b.chord(x, y, g.ChordThreshold)
+	return g.finishMove(ReplayChord, x, y, before, hit, changed)
+}
+
+// finishMove does the bookkeeping after a reveal or chord at (x,y): undo,
+// replay, timer, events and the end of the game.
+func (g *game) finishMove(action ReplayAction, x, y int, before boardSnapshot, hit, changed bool) bool {
+	typ := "reveal"
+	if action == ReplayChord {
+		typ = "chord"
+	}
+	g.logEvent(typ, x, y, moveResult(hit, changed))
+	if action == ReplayChord {
+		g.events.Publish(ChordUsedEvent{X: x, Y: y, Opened: changed})
+	}
+	if changed {
+		g.pushUndo(before)
+		g.events.Publish(MoveEvent{Action: action, X: x, Y: y})
+	}
+
+	if changed && g.timerStart.IsZero() && g.b.placed {
+		g.timerStart = time.Now()
+		g.bv3 = g.b.Compute3BV()
+		if g.diff.Daily != "" && g.playback == nil {
+			g.recordDailyAttempt()
+		}
+		g.events.Publish(GameStartedEvent{})
+	}
+	if changed {
+		g.clearHints()
+	}
+
+	if hit {
+		g.events.Publish(MineHitEvent{X: x, Y: y})
+		g.onGameLost()
+		return true
+	}
+	if changed {
+		g.events.Publish(CellRevealedEvent{X: x, Y: y, Adjacent: g.b.cells[y][x].Adjacent})
+	}
+	if g.b.isWin() {
+		g.onGameWon()
+	}
+	return changed
+}
+
+func (g *game) handleMarkAt(mx, my int) bool {
+	mx, my = g.normalizeInputPos(mx, my)
+	if g.toggleTouchModeAt(mx, my) {
+		return true
+	}
+	if g.noFlags || g.paused || g.state != statePlaying || g.showHelp || g.showScores {
+		return false
+	}
+	x, y, ok := g.boardPosFromCursor(mx, my)
+	if !ok {
+		return false
+	}
+	g.rightClicks++
+	return g.markCell(x, y)
+}
+
+// markCell cycles the flag / question mark on (x,y). In the no-flags
+// variant it does nothing, except for a replay recorded without it.
+func (g *game) markCell(x, y int) bool {
+	if g.noFlags && g.playback == nil {
+		return false
+	}
+	before := g.b.snapshot()
+	changed := g.b.toggleMark(x, y, g.allowQuestion)
+	g.logEvent("flag", x, y, moveResult(false, changed))
+	if changed {
+		g.pushUndo(before)
+		g.events.Publish(MoveEvent{Action: ReplayFlag, X: x, Y: y})
+		if c := g.b.cells[y][x]; c.Flagged() {
+			if !c.Mine {
+				g.noteWrongFlag(x, y)
+			}
+			g.events.Publish(FlagPlacedEvent{X: x, Y: y})
+		}
+		g.clearHints()
+		return true
+	}
+	return false
+}
+
+// setFlag flags (x,y) whatever mark it carries, the way the auto-flaggers
+// do. It is how a playback repeats their moves.
+func (g *game) setFlag(x, y int) bool {
+	before := g.b.snapshot()
+	c := &g.b.cells[y][x]
+	if !c.Flag() {
 		return false
 	}
-
-	x, y, ok := g.boardPosFromCursor(mx, my)
-	if !ok {
-		return false
+	g.b.flagsCnt++
+	g.pushUndo(before)
+	if !c.Mine {
+		g.noteWrongFlag(x, y)
 	}
+	g.clearHints()
+	return true
+}
 
-	var hit, changed bool
-	if g.b.cells[y][x].Revealed {
-		hit, changed = g.b.chord(x, y)
-	} else {
-		hit, changed = g.b.reveal(x, y)
+// handleCursorKeys moves the keyboard cursor and plays at it: Space reveals,
+// F marks and Enter chords.
+func (g *game) handleCursorKeys() {
+	moves := []struct {
+		key    ebiten.Key
+		dx, dy int
+	}{
+		{ebiten.KeyLeft, -1, 0},
+		{ebiten.KeyRight, 1, 0},
+		{ebiten.KeyUp, 0, -1},
+		{ebiten.KeyDown, 0, 1},
 	}
-
-	if changed && g.timerStart.IsZero() && g.b.placed {
-		g.timerStart = time.Now()
+	for _, m := range moves {
+		if keyRepeated(m.key) {
+			g.cursor.X = clamp(g.cursor.X+m.dx, 0, g.b.W-1)
+			g.cursor.Y = clamp(g.cursor.Y+m.dy, 0, g.b.H-1)
+			g.cursorShown = true
+			g.scrollToCell(g.cursor.X, g.cursor.Y)
+		}
 	}
-	if changed {
-		g.hint = nil
+	if keyJustPressed(ebiten.KeyTab) {
+		g.cursor = g.nextHiddenCell(g.cursor)
+		g.cursorShown = true
+		g.scrollToCell(g.cursor.X, g.cursor.Y)
 	}
 
-	if hit {
-		g.state = stateLost
-		g.b.revealAllMines()
-		return true
+	if !g.cursorShown || g.paused || g.state != statePlaying || g.showHelp || g.showScores {
+		return
 	}
-	if g.b.isWin() {
-		g.onGameWon()
+	x, y := g.cursor.X, g.cursor.Y
+	switch {
+	case keyJustPressed(ebiten.KeySpace):
+		if g.b.cells[y][x].Revealed() {
+			g.chordClicks++
+		} else {
+			g.leftClicks++
+		}
+		g.revealCell(x, y)
+	case keyJustPressed(ebiten.KeyF):
+		g.rightClicks++
+		g.markCell(x, y)
+	case keyJustPressed(ebiten.KeyEnter):
+		g.chordClicks++
+		g.chordCell(x, y)
 	}
-	return changed
 }
 
-func (g *game) handleMarkAt(mx, my int) bool {
-	mx, my = g.normalizeInputPos(mx, my)
-	if g.toggleTouchModeAt(mx, my) {
-		return true
+// nextHiddenCell returns the first hidden, unmarked cell after from in
+// reading order, wrapping around. It returns from when there is none.
+func (g *game) nextHiddenCell(from point) point {
+	n := g.b.W * g.b.H
+	start := from.Y*g.b.W + from.X
+	for i := 1; i <= n; i++ {
+		j := (start + i) % n
+		x, y := j%g.b.W, j/g.b.W
+		if c := g.b.cells[y][x]; !c.Revealed() && !c.Flagged() {
+			return point{X: x, Y: y}
+		}
 	}
+	return from
+}
+
+// handleChordAt chords the cell under a middle click.
+func (g *game) handleChordAt(mx, my int) bool {
+	mx, my = g.normalizeInputPos(mx, my)
 	if g.paused || g.state != statePlaying || g.showHelp || g.showScores {
 		return false
 	}
@@ -603,14 +2419,55 @@ func (g *game) handleMarkAt(mx, my int) bool {
 	if !ok {
 		return false
 	}
-	if g.b.toggleMark(x, y, g.allowQuestion) {
-		g.hint = nil
+	g.chordClicks++
+	return g.chordCell(x, y)
+}
+
+// handlePrimaryAt runs the action for a left click or tap: reveal normally,
+// mark in quick flag mode. The smiley always restarts.
+func (g *game) handlePrimaryAt(mx, my int) bool {
+	if !g.QuickFlagMode {
+		return g.handleRevealAt(mx, my)
+	}
+	if nx, ny := g.normalizeInputPos(mx, my); pointInRect(nx, ny, g.faceRect) {
+		g.reset(false)
 		return true
 	}
-	return false
+	return g.handleMarkAt(mx, my)
+}
+
+// handleSecondaryAt runs the action for a right click or long press.
+func (g *game) handleSecondaryAt(mx, my int) bool {
+	if g.QuickFlagMode {
+		return g.handleRevealAt(mx, my)
+	}
+	return g.handleMarkAt(mx, my)
+}
+
+// mouseButtons returns the buttons that act as a click and a right click;
+// left-handed mode swaps them. Touch is not affected.
+func (g *game) mouseButtons() (primary, secondary ebiten.MouseButton) {
+	if g.leftHanded {
+		return ebiten.MouseButtonRight, ebiten.MouseButtonLeft
+	}
+	return ebiten.MouseButtonLeft, ebiten.MouseButtonRight
+}
+
+// mouseHelp describes the mouse buttons as they are currently mapped.
+func (g *game) mouseHelp() string {
+	primary, secondary := "Left", "Right"
+	if g.leftHanded {
+		primary, secondary = secondary, primary
+	}
+	reveal, mark := primary, secondary
+	if g.QuickFlagMode {
+		reveal, mark = mark, reveal
+	}
+	return fmt.Sprintf("%s click: Reveal / Chord | %s click: Flag/? | Middle click: Chord | Ctrl+%s click: Lock", reveal, mark, secondary)
 }
 
 func (g *game) handleTouchInput() {
+	g.handlePinch()
 	for _, id := range ebiten.TouchIDs() {
 		x, y := ebiten.TouchPosition(id)
 		st, ok := g.touchStarts[id]
@@ -618,6 +2475,7 @@ func (g *game) handleTouchInput() {
 			g.touchStarts[id] = touchStart{X: x, Y: y, LastX: x, LastY: y, At: time.Now()}
 			continue
 		}
+		g.dragFlag(&st, x, y)
 		st.LastX, st.LastY = x, y
 		g.touchStarts[id] = st
 	}
@@ -636,91 +2494,473 @@ func (g *game) handleTouchInput() {
 
 		dx := absInt(st.LastX - st.X)
 		dy := absInt(st.LastY - st.Y)
-		if dx > touchMoveSlopPx || dy > touchMoveSlopPx {
+		if dx > touchMoveSlopPx || dy > touchMoveSlopPx || st.dragFlagMode || st.pinched {
 			continue
 		}
 
 		if time.Since(st.At) >= touchLongPressDur {
-			g.handleMarkAt(st.LastX, st.LastY)
+			g.handleSecondaryAt(st.LastX, st.LastY)
 			continue
 		}
 		if g.touchFlagMode {
-			g.handleMarkAt(st.LastX, st.LastY)
+			g.handleSecondaryAt(st.LastX, st.LastY)
 			continue
 		}
-		g.handleRevealAt(st.LastX, st.LastY)
+		g.handlePrimaryAt(st.LastX, st.LastY)
+	}
+}
+
+// dragFlag follows a touch that moved to (x,y). Once it leaves its start
+// slowly, every cell it enters is marked: hidden cells get a flag and
+// flagged ones lose it.
+func (g *game) dragFlag(st *touchStart, x, y int) {
+	if st.swiped || st.pinched || g.paused || g.state != statePlaying || g.showHelp || g.showScores {
+		return
+	}
+	if !st.dragFlagMode {
+		if absInt(x-st.X) <= touchMoveSlopPx && absInt(y-st.Y) <= touchMoveSlopPx {
+			return
+		}
+		speed := math.Hypot(float64(x-st.LastX), float64(y-st.LastY)) / float64(cellSizePx)
+		if speed >= dragFlagMaxSpeed {
+			st.swiped = true
+			return
+		}
+		st.dragFlagMode = true
+		sx, sy := g.normalizeInputPos(st.X, st.Y)
+		if cx, cy, ok := g.boardPosFromCursor(sx, sy); ok {
+			st.dragCell = point{X: cx, Y: cy}
+			g.dragMark(cx, cy)
+		} else {
+			st.dragCell = point{X: -1, Y: -1}
+		}
+	}
+	nx, ny := g.normalizeInputPos(x, y)
+	cx, cy, ok := g.boardPosFromCursor(nx, ny)
+	if !ok || (point{X: cx, Y: cy}) == st.dragCell {
+		return
+	}
+	st.dragCell = point{X: cx, Y: cy}
+	g.dragMark(cx, cy)
+}
+
+// dragMark flags (x,y), or clears it if it is flagged. It goes through
+// markCell, stepping over the question mark where needed, so undo and
+// replays see ordinary marks.
+func (g *game) dragMark(x, y int) {
+	c := g.b.cells[y][x]
+	if c.Revealed() {
+		return
+	}
+	g.rightClicks++
+	g.markCell(x, y)
+	// a question mark comes between flag and hidden in the cycle
+	if c.Question() || g.b.cells[y][x].Question() {
+		g.markCell(x, y)
 	}
 }
 
 func (g *game) handleGlobalKeys() {
-	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+	if keyJustPressed(ebiten.KeyN) {
 		g.reset(false)
 	}
-	if inpututil.IsKeyJustPressed(ebiten.Key1) || inpututil.IsKeyJustPressed(ebiten.KeyB) {
+	// inside the custom dialog Ctrl+S edits the seed instead
+	if ctrlKeyJustPressed(ebiten.KeyS) && !g.showCustom {
+		if err := g.Save(); err != nil {
+			g.showToast("Save failed: " + err.Error())
+		} else {
+			g.savedUntil = time.Now().Add(time.Second)
+		}
+	}
+	if ctrlKeyJustPressed(ebiten.KeyL) && !g.showCustom {
+		if err := g.Load(); err != nil {
+			g.showToast("Load failed: " + err.Error())
+		} else {
+			g.showToast("Game loaded")
+		}
+	}
+	if keyJustPressed(ebiten.KeyD) {
+		g.startDaily()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyZ) || keyJustPressed(ebiten.KeyU) {
+		g.undoMove()
+	}
+	if shiftKeyJustPressed(ebiten.KeyN) {
+		g.seedPrompt.open()
+		g.cursorBlink = true
+		g.lastBlink = time.Now()
+	}
+	if keyJustPressed(ebiten.KeyR) {
+		g.retry()
+	}
+	if shiftKeyJustPressed(ebiten.KeyR) {
+		g.toggleReplays()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyR) {
+		g.togglePractice()
+	}
+	if shiftKeyJustPressed(ebiten.KeyP) {
+		g.toggleFlagPenalty()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyN) {
+		g.toggleNoFlags()
+	}
+	// plain E is Expert and Shift+E explains a win
+	if ctrlShiftKeyJustPressed(ebiten.KeyE) {
+		g.toggleEfficiency()
+	}
+	if ctrlShiftKeyJustPressed(ebiten.KeyA) {
+		g.toggleAnimations()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyM) {
+		g.toggleMarathon()
+	}
+	if keyJustPressed(ebiten.Key1) || keyJustPressed(ebiten.KeyB) {
 		g.setDifficulty(presets[0])
 	}
-	if inpututil.IsKeyJustPressed(ebiten.Key2) || inpututil.IsKeyJustPressed(ebiten.KeyI) {
+	if keyJustPressed(ebiten.Key2) || keyJustPressed(ebiten.KeyI) {
 		g.setDifficulty(presets[1])
 	}
-	if inpututil.IsKeyJustPressed(ebiten.Key3) || inpututil.IsKeyJustPressed(ebiten.KeyE) {
+	if keyJustPressed(ebiten.Key3) || keyJustPressed(ebiten.KeyE) {
 		g.setDifficulty(presets[2])
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+	if keyJustPressed(ebiten.KeyT) {
 		g.themeIdx = (g.themeIdx + 1) % len(themes)
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+	// '+' is Shift+= on most layouts
+	if keyJustPressed(ebiten.KeyEqual) || shiftKeyJustPressed(ebiten.KeyEqual) || keyJustPressed(ebiten.KeyKPAdd) {
+		g.setCellSize(cellSizePx + 2)
+	}
+	if ctrlKeyJustPressed(ebiten.KeyEqual) || ctrlKeyJustPressed(ebiten.KeyKPAdd) {
+		g.setZoom(g.zoom() + zoomStep)
+	}
+	if ctrlKeyJustPressed(ebiten.KeyMinus) || ctrlKeyJustPressed(ebiten.KeyKPSubtract) {
+		g.setZoom(g.zoom() - zoomStep)
+	}
+	if ctrlKeyJustPressed(ebiten.Key0) {
+		g.setZoom(1)
+	}
+	if keyJustPressed(ebiten.KeyMinus) || keyJustPressed(ebiten.KeyKPSubtract) {
+		g.setCellSize(cellSizePx - 2)
+	}
+	if keyJustPressed(ebiten.KeyQ) {
 		g.allowQuestion = !g.allowQuestion
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+	if keyJustPressed(ebiten.KeyF11) {
+		g.toggleFullscreen()
+	}
+	if keyJustPressed(ebiten.KeyF1) {
 		g.showHelp = !g.showHelp
 		if g.showHelp {
 			g.showScores = false
 			g.showCustom = false
+			g.showReplays = false
 		}
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+	if keyJustPressed(ebiten.KeyS) {
 		g.showScores = !g.showScores
 		if g.showScores {
 			g.showHelp = false
 			g.showCustom = false
+			g.showReplays = false
+		}
+	}
+	if ctrlShiftKeyJustPressed(ebiten.KeyS) {
+		g.exportSVG()
+	}
+	if ctrlShiftKeyJustPressed(ebiten.KeyT) {
+		g.exportTikZ()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyT) {
+		g.cycleCountdown()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyP) || keyJustPressed(ebiten.KeyPrintScreen) {
+		if err := g.screenshot(); err != nil {
+			g.showToast("Screenshot failed: " + err.Error())
+		} else {
+			g.showToast("Screenshot saved")
 		}
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+	if shiftKeyJustPressed(ebiten.KeyQ) {
+		g.QuickFlagMode = !g.QuickFlagMode
+	}
+	if keyJustPressed(ebiten.KeyL) {
+		g.toggleLeftHanded()
+	}
+	if keyJustPressed(ebiten.KeyG) {
+		g.toggleNoGuess()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyO) {
+		g.toggleSettings()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyE) {
+		g.toggleEditor()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyW) {
+		g.toggleWhatIf()
+	}
+	if ctrlKeyJustPressed(ebiten.KeyG) {
+		g.toggleTournament()
+	}
+	if keyJustPressed(ebiten.KeyW) {
+		g.Toroidal = !g.Toroidal
+		if !g.b.placed {
+			g.b.Toroidal = g.Toroidal
+		}
+		g.showToast(fmt.Sprintf("Toroidal board: %v (from the next layout)", g.Toroidal))
+	}
+	if shiftKeyJustPressed(ebiten.KeyS) {
+		g.toggleSymmetric()
+	}
+	if shiftKeyJustPressed(ebiten.KeyI) {
+		g.Clustered = !g.Clustered
+		if !g.b.placed {
+			g.b.ClusteredMines = g.Clustered
+		}
+		g.showToast(fmt.Sprintf("Clustered mines: %v (from the next layout)", g.Clustered))
+	}
+	if shiftKeyJustPressed(ebiten.KeyH) {
+		g.AntiHintMode = !g.AntiHintMode
+		g.antiHint = nil
+	}
+	if shiftKeyJustPressed(ebiten.KeyF) {
+		g.showCorroborated = !g.showCorroborated
+	}
+	if shiftKeyJustPressed(ebiten.KeyK) {
+		g.showChordable = !g.showChordable
+	}
+	if ctrlKeyJustPressed(ebiten.KeyF) {
+		g.showFrontier = !g.showFrontier
+	}
+	if shiftKeyJustPressed(ebiten.KeyG) {
+		g.showGroups = !g.showGroups
+	}
+	if keyJustPressed(ebiten.KeyO) {
+		g.showProbability = !g.showProbability
+	}
+	if shiftKeyJustPressed(ebiten.KeyC) {
+		g.showCoordinates = !g.showCoordinates
+	}
+	if shiftKeyJustPressed(ebiten.KeyE) {
+		if g.showExplain {
+			g.closeExplain()
+		} else if g.state == stateWon {
+			var s Solver
+			g.explain = s.ExplainSolution(g.b)
+			g.explainIdx = 0
+			g.showExplain = len(g.explain) > 0
+		}
+	}
+	if keyJustPressed(ebiten.KeyC) {
 		g.showCustom = !g.showCustom
 		if g.showCustom {
 			g.showHelp = false
 			g.showScores = false
+			g.cursorBlink = true
+			g.lastBlink = time.Now()
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyP) && g.state == statePlaying {
-		g.paused = !g.paused
-		if g.paused {
-			g.pauseStarted = time.Now()
-		} else if !g.pauseStarted.IsZero() && !g.timerStart.IsZero() {
-			g.timerStart = g.timerStart.Add(time.Since(g.pauseStarted))
-			g.pauseStarted = time.Time{}
+	if keyJustPressed(ebiten.KeyP) && g.state == statePlaying {
+		g.setPaused(!g.paused, time.Now())
+		g.autopaused = false
+	}
+
+	if shiftKeyJustPressed(ebiten.Key1) {
+		g.autoFlagSingles()
+	}
+
+	if ctrlKeyJustPressed(ebiten.KeyA) {
+		g.autoSolveAll()
+	}
+	if keyJustPressed(ebiten.KeyA) {
+		g.autoFlag()
+	}
+
+	if keyJustPressed(ebiten.KeyH) && g.NoGuess {
+		g.showToast("No hints in no-guess mode")
+	} else if keyJustPressed(ebiten.KeyH) && g.state == statePlaying && !g.paused && g.hintAvailable() {
+		revealed, flagged, _ := g.b.SolveStep()
+		switch {
+		case len(revealed) > 0:
+			g.hint = &point{X: revealed[0][0], Y: revealed[0][1]}
+			g.hintReason = "Deduced safe"
+			g.spendHint()
+			g.logEvent("hint", g.hint.X, g.hint.Y, "safe")
+			g.events.Publish(HintUsedEvent{Reason: g.hintReason})
+		case len(flagged) > 0:
+			// drawn with the anti-hint's red outline
+			g.antiHint = &point{X: flagged[0][0], Y: flagged[0][1]}
+			g.hintReason = "Deduced mine"
+			g.spendHint()
+			g.logEvent("hint", g.antiHint.X, g.antiHint.Y, "mine")
+			g.events.Publish(HintUsedEvent{Reason: g.hintReason})
+		default:
+			if x, y, reason, ok := g.b.findSafeHint(g.HintDeterministic); ok {
+				g.hint = &point{X: x, Y: y}
+				g.hintReason = reason
+				g.spendHint()
+				g.logEvent("hint", x, y, "safe")
+				g.events.Publish(HintUsedEvent{Reason: g.hintReason})
+			} else {
+				g.logEvent("hint", -1, -1, "noop")
+			}
+		}
+		if g.AntiHintMode && g.b.placed {
+			if ax, ay, p, ok := g.b.riskiestCell(); ok {
+				g.antiHint = &point{X: ax, Y: ay}
+				g.hintReason = fmt.Sprintf("Highest risk (%.0f%%)", p*100)
+			}
 		}
 	}
+}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyH) && g.state == statePlaying && !g.paused {
-		x, y, ok := g.b.findSafeHint()
+// pollPlacement picks up a finished first-click layout and performs the
+// reveal that was waiting on it.
+func (g *game) pollPlacement() {
+	if g.placing == nil {
+		return
+	}
+	select {
+	case nb, ok := <-g.placing:
+		g.cancelPlacement()
 		if ok {
-			g.hint = &point{X: x, Y: y}
+			g.b.adoptLayout(nb)
+			g.revealCell(g.pendingReveal.X, g.pendingReveal.Y)
 		}
+	default:
+	}
+}
+
+// cancelPlacement abandons a first-click layout that is still being built.
+func (g *game) cancelPlacement() {
+	if g.cancelPlacing != nil {
+		g.cancelPlacing()
+	}
+	g.placing = nil
+	g.cancelPlacing = nil
+}
+
+func (g *game) closeExplain() {
+	g.showExplain = false
+	g.explain = nil
+	g.explainIdx = 0
+}
+
+// handleExplainKeys steps through the deduction overlay.
+func (g *game) handleExplainKeys() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+		g.explainIdx = min(g.explainIdx+1, len(g.explain)-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+		g.explainIdx = max(g.explainIdx-1, 0)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.closeExplain()
+	}
+}
+
+func (g *game) clearHints() {
+	g.hint = nil
+	g.antiHint = nil
+	g.hintReason = ""
+	g.hintPulse = 0
+}
+
+func (g *game) exportTikZ() {
+	path := texExportPath
+	if path == "" {
+		path = configFilePath("board.tex")
+	}
+	data := g.b.ToTikZ(g.state != statePlaying)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		g.showToast("TikZ export failed: " + err.Error())
+		return
+	}
+	g.showToast("Saved " + path)
+}
+
+// ExportSVG writes the board in the current theme at svgCellSize units
+// per cell. Hidden mines show once the game is over.
+func (g *game) ExportSVG(w io.Writer) error {
+	return g.b.ExportSVG(w, themes[g.themeIdx], svgCellSize, g.state != statePlaying)
+}
+
+func (g *game) exportSVG() {
+	path := svgExportPath
+	if path == "" {
+		path = configFilePath("board.svg")
+	}
+	var sb strings.Builder
+	g.ExportSVG(&sb)
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		g.showToast("SVG export failed: " + err.Error())
+		return
+	}
+	g.showToast("Saved " + path)
+}
+
+// progressMinStep keeps the tail of the glide short: the bar moves at least
+// this much per frame, so it settles within 30 frames of a jump to 100%.
+const progressMinStep = 0.02
+
+func (g *game) stepProgress() {
+	g.targetProgress = float64(g.b.revealedCnt) / float64(g.b.W*g.b.H-g.b.Mines)
+	d := g.targetProgress - g.displayedProgress
+	step := d * 0.1
+	if math.Abs(step) < progressMinStep {
+		step = math.Copysign(math.Min(progressMinStep, math.Abs(d)), d)
+	}
+	g.displayedProgress += step
+	if g.progressPulse > 0 {
+		g.progressPulse--
+	}
+}
+
+func (g *game) showToast(msg string) {
+	g.toast = msg
+	g.toastUntil = time.Now().Add(2 * time.Second)
+}
+
+// cursorBlinkInterval matches the usual text caret rate.
+const cursorBlinkInterval = 530 * time.Millisecond
+
+func (g *game) updateCursorBlink(now time.Time) {
+	if now.Sub(g.lastBlink) >= cursorBlinkInterval {
+		g.cursorBlink = !g.cursorBlink
+		g.lastBlink = now
 	}
 }
 
 func (g *game) handleCustomDialog() {
+	if g.custom.seedInput.active {
+		if v, ok := g.custom.seedInput.update(); ok {
+			g.custom.Seed = v
+			g.custom.hasSeed = true
+		}
+		return
+	}
+	if ctrlKeyJustPressed(ebiten.KeyS) {
+		g.custom.seedInput.open()
+		return
+	}
+	if ctrlKeyJustPressed(ebiten.KeyC) {
+		seed := strconv.FormatInt(g.b.seed, 10)
+		if err := copyToClipboard(seed); err != nil {
+			g.showToast("Clipboard unavailable: " + seed)
+		} else {
+			g.showToast("Seed copied")
+		}
+	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
 		g.showCustom = false
 		return
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
-		g.custom.field = (g.custom.field + 2) % 3
+		g.custom.field = (g.custom.field + customFields - 1) % customFields
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
-		g.custom.field = (g.custom.field + 1) % 3
+		g.custom.field = (g.custom.field + 1) % customFields
 	}
 
 	delta := 0
@@ -734,12 +2974,24 @@ func (g *game) handleCustomDialog() {
 	if delta != 0 {
 		switch g.custom.field {
 		case 0:
-			g.custom.W = clamp(g.custom.W+delta, 9, 60)
+			g.custom.W = clamp(g.custom.W+delta, 9, maxBoardW)
 		case 1:
-			g.custom.H = clamp(g.custom.H+delta, 9, 32)
+			g.custom.H = clamp(g.custom.H+delta, 9, maxBoardH)
 		case 2:
 			maxM := g.custom.W*g.custom.H - 1
 			g.custom.Mines = clamp(g.custom.Mines+delta, 10, maxM)
+		case 3:
+			// applies immediately: it is a play setting, not part of the board
+			g.ChordThreshold = clamp(g.ChordThreshold+delta, -2, 2)
+		case 4:
+			if g.custom.Connectivity == Connectivity8 {
+				g.custom.Connectivity = Connectivity4
+			} else {
+				g.custom.Connectivity = Connectivity8
+			}
+		case 5:
+			// like the toroidal switch, it applies from the next layout
+			g.SafeZone = clamp(g.SafeZone+delta, 0, maxSafePadding)
 		}
 		maxM := g.custom.W*g.custom.H - 1
 		if g.custom.Mines > maxM {
@@ -753,41 +3005,182 @@ func (g *game) handleCustomDialog() {
 			W:     g.custom.W,
 			H:     g.custom.H,
 			Mines: g.custom.Mines,
+
+			Connectivity: g.custom.Connectivity,
 		})
+		if g.custom.hasSeed {
+			g.b.SetSeed(g.custom.Seed)
+		}
 		g.showCustom = false
 	}
 }
 
+// setPaused pauses or resumes the timer. Time spent paused is added to
+// timerStart on resume so elapsed skips over it.
+func (g *game) setPaused(paused bool, now time.Time) {
+	if paused == g.paused {
+		return
+	}
+	g.paused = paused
+	if paused {
+		g.pauseStarted = now
+	} else if !g.pauseStarted.IsZero() && !g.timerStart.IsZero() {
+		g.timerStart = g.timerStart.Add(now.Sub(g.pauseStarted))
+		g.pauseStarted = time.Time{}
+	}
+}
+
+// handleFocus pauses a running game when the window loses focus and
+// resumes it when focus returns, unless the player paused it by hand.
+func (g *game) handleFocus(focused bool, now time.Time) {
+	if !g.AutoPauseOnFocusLoss {
+		return
+	}
+	if !focused && g.state == statePlaying && !g.paused {
+		g.setPaused(true, now)
+		g.autopaused = true
+	} else if focused && g.autopaused {
+		g.autopaused = false
+		if g.state == statePlaying {
+			g.setPaused(false, now)
+		}
+	}
+}
+
 func (g *game) Update() error {
-	g.handleGlobalKeys()
+	if g.quit.Load() || ebiten.IsWindowBeingClosed() {
+		g.captureWindow()
+		return ebiten.Termination
+	}
+	g.telemetry.tick(time.Now())
+	g.stepParticles()
+	g.stepConfetti()
+	g.stepHintPulse()
+	g.handleFocus(ebiten.IsFocused(), time.Now())
+
+	if g.seedPrompt.active {
+		g.updateCursorBlink(time.Now())
+		if v, ok := g.seedPrompt.update(); ok {
+			g.startSeeded(v)
+		}
+		return nil
+	}
+
+	if g.playback != nil {
+		g.stepProgress()
+		g.stepRevealQueue()
+		g.updatePlayback()
+		return nil
+	}
+	if g.showReplays {
+		g.handleReplayList()
+		return nil
+	}
+	if g.showMarathon {
+		g.handleMarathonSummary()
+		return nil
+	}
+	if g.showTournament {
+		g.handleTournamentSummary()
+		return nil
+	}
+	if g.showSettings {
+		g.handleSettingsPanel()
+		return nil
+	}
+	if g.editorMode {
+		g.handleEditor()
+		return nil
+	}
+	if g.whatIf {
+		g.handleWhatIf()
+		return nil
+	}
+	if g.contextMenu != nil {
+		g.handleContextMenu()
+		return nil
+	}
+	if g.marathonNext {
+		g.marathonNext = false
+		g.reset(false)
+	}
+	if g.tournamentNext {
+		g.tournamentNext = false
+		g.startTournamentRound()
+	}
+
+	// typed seed digits must not switch difficulty
+	if !g.custom.seedInput.active {
+		g.handleGlobalKeys()
+	}
 
 	if g.showCustom {
+		g.updateCursorBlink(time.Now())
 		g.handleCustomDialog()
 		return nil
 	}
+	if g.practicePending != statePlaying {
+		// the clock stays stopped while a practice outcome is held
+		g.handlePracticePending()
+		return nil
+	}
 
 	if g.state == statePlaying && g.b.placed && !g.timerStart.IsZero() && !g.paused {
-		g.elapsedSeconds = int(time.Since(g.timerStart).Seconds())
-		if g.elapsedSeconds > 999 {
-			g.elapsedSeconds = 999
-		}
+		g.elapsed = time.Since(g.timerStart)
+	}
+	g.checkCountdown()
+
+	g.stepProgress()
+	g.pollPlacement()
+	g.stepRevealQueue()
+	if len(g.revealQueue) > 0 {
+		// the board is busy until the cascade finishes
+		return nil
+	}
+
+	if g.showExplain {
+		g.handleExplainKeys()
+	} else {
+		g.handleCursorKeys()
 	}
+	g.clampView()
+	g.handleScrollKeys()
 
 	mx, my := ebiten.CursorPosition()
 
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		g.handleRevealAt(mx, my)
+	primary, secondary := g.mouseButtons()
+	if inpututil.IsMouseButtonJustPressed(primary) {
+		if !altHeld() || !g.inspectCellAt(mx, my) {
+			g.handlePrimaryAt(mx, my)
+		}
 	}
 
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
-		g.handleMarkAt(mx, my)
+	if inpututil.IsMouseButtonJustPressed(secondary) {
+		switch {
+		case ctrlHeld():
+			g.handleLockAt(mx, my)
+		case g.useContextMenu && g.openContextMenu(mx, my):
+		default:
+			g.handleSecondaryAt(mx, my)
+		}
 	}
 
+	g.handleMiddleMouse(mx, my)
+
 	g.handleTouchInput()
 	return nil
 }
 
 func (g *game) Draw(screen *ebiten.Image) {
+	if g.fullscreen {
+		g.drawLetterboxed(screen)
+		return
+	}
+	g.drawGame(screen)
+}
+
+// drawGame draws one frame onto an image of the size Layout(0, 0) reports.
+func (g *game) drawGame(screen *ebiten.Image) {
 	th := themes[g.themeIdx]
 	screen.Fill(th.BG)
 
@@ -800,9 +3193,40 @@ func (g *game) Draw(screen *ebiten.Image) {
 	ebitenutil.DrawRect(screen, float64(outerPadding+4), 16, float64(windowW-outerPadding*2-8), 40, th.Panel)
 
 	mineVal := g.b.remainingMines()
-	timerVal := g.elapsedSeconds
-	drawDigital(screen, outerPadding+10, 20, mineVal, 3, th.Digit)
-	drawDigital(screen, windowW-outerPadding-10-58, 20, timerVal, 3, th.Digit)
+	if g.noFlags {
+		// nothing is flagged, so count what the numbers give away
+		mineVal = g.b.Mines - g.b.DeducedMines()
+	}
+	var mineClr color.Color = th.Digit
+	if g.countdownUrgent() && time.Now().UnixMilli()/250%2 == 0 {
+		mineClr = rgb(255, 30, 30)
+	}
+	drawDigital(screen, outerPadding+10, 20, mineVal, 3, mineClr)
+	shown := g.elapsed
+	if g.CountdownSec > 0 {
+		shown = g.countdownLeft()
+	}
+	// centiseconds only fit when the board is wider than Beginner
+	if g.efficiencyMode {
+		drawDigital(screen, windowW-outerPadding-10-3*segDigitW()-4, 20, g.clickCount(), 3, th.Digit)
+	} else if windowW >= digitalMsMinWindowW {
+		drawDigitalMs(screen, windowW-outerPadding-10-digitalMsWidth(), 20, shown, th.Digit)
+	} else {
+		drawDigital(screen, windowW-outerPadding-10-3*segDigitW()-4, 20, int(math.Ceil(shown.Seconds())), 3, th.Digit)
+	}
+
+	// progress bar along the bottom of the inner panel
+	barX, barW := outerPadding+8, windowW-outerPadding*2-16
+	ebitenutil.DrawRect(screen, float64(barX), 50, float64(barW), 4, th.Dark)
+	var barColor color.Color = th.Accent
+	if g.state == stateWon {
+		barColor = rgb(230, 180, 30)
+	}
+	ebitenutil.DrawRect(screen, float64(barX), 50, float64(barW)*g.displayedProgress, 4, barColor)
+	if g.progressPulse > 0 {
+		a := math.Sin(float64(g.progressPulse) / 60 * math.Pi * 3)
+		ebitenutil.DrawRect(screen, float64(barX), 49, float64(barW), 6, withAlpha(rgb(255, 240, 160), uint8(math.Abs(a)*160)))
+	}
 
 	// face button
 	faceSize := 28
@@ -821,7 +3245,17 @@ func (g *game) Draw(screen *ebiten.Image) {
 			face = ":|"
 		}
 	}
-	drawTextCentered(screen, face, g.fontMain, faceX, faceY+6, faceSize, th.HeaderText)
+	if time.Now().Before(g.savedUntil) {
+		drawFloppy(screen, faceX+6, faceY+6, faceSize-12, th)
+	} else {
+		drawTextCentered(screen, face, g.fontMain, faceX, faceY+6, faceSize, th.HeaderText)
+	}
+	if g.efficiencyMode && g.state == statePlaying {
+		g.drawClickBadge(screen, faceX, faceY, faceSize, th)
+	}
+	if g.hintsRemaining >= 0 && g.state == statePlaying && !g.NoGuess {
+		g.drawHintBadge(screen, faceX, faceY, th)
+	}
 
 	// touch mode toggle (especially useful on mobile browsers)
 	tw, thh := 62, 18
@@ -832,74 +3266,332 @@ func (g *game) Draw(screen *ebiten.Image) {
 	if g.touchFlagMode {
 		touchLabel = "T:FLAG"
 	}
-	drawTextCentered(screen, touchLabel, basicfont.Face7x13, tx, ty+3, tw, th.HeaderText)
+	drawTextCentered(screen, touchLabel, basicfont.Face7x13, tx, ty+3, tw, th.HeaderText)
+
+	// board frame
+	br := g.boardRect()
+	drawSunkenRect(screen, br.Min.X-2, br.Min.Y-2, br.Dx()+4, br.Dy()+4, th)
+	g.drawScrollbars(screen, th)
+
+	// cells only draw inside the frame, and only those on screen
+	boardImg := screen.SubImage(br).(*ebiten.Image)
+	// only the board shakes, never the panel or overlays
+	g.shake = g.nextShake()
+	x0, y0, x1, y1 := g.visibleCells()
+	g.frontier = nil
+	if g.showFrontier && g.b.placed && g.state == statePlaying {
+		g.frontier = g.b.frontierMask()
+	}
+	g.groupOf = nil
+	if g.showGroups && g.b.placed && g.state == statePlaying {
+		g.groupOf = g.b.groupMask()
+	}
+	g.heatmap = nil
+	if g.showProbability && g.b.placed && g.state == statePlaying {
+		g.heatmap = g.b.MineHeatmap()
+	}
+	board := g.zoomTarget(boardImg)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			g.drawCell(board, x, y, th)
+		}
+	}
+	if g.cursorShown && g.b.in(g.cursor.X, g.cursor.Y) {
+		cx, cy := g.cellOrigin(g.cursor.X, g.cursor.Y)
+		vector.StrokeRect(board, float32(cx+1), float32(cy+1), float32(cellSizePx-2), float32(cellSizePx-2), 2, th.Accent, false)
+	}
 
-	// board frame
-	boardX, boardY := outerPadding, topPanelHeight
-	bw := g.b.W * cellSize
-	bh := g.b.H * cellSize
-	drawSunkenRect(screen, boardX-2, boardY-2, bw+4, bh+4, th)
+	if g.showExplain {
+		g.drawExplainHighlights(board)
+	}
+	g.drawParticles(board)
+	g.finishZoom(boardImg)
+	g.shake = point{}
+	g.drawConfetti(screen)
 
-	for y := 0; y < g.b.H; y++ {
-		for x := 0; x < g.b.W; x++ {
-			g.drawCell(screen, x, y, th)
+	label := g.diff.Name
+	switch {
+	case g.diff.Daily != "":
+		label = "Daily " + g.diff.Daily
+	case g.diff.Puzzle != "":
+		label = "[Puzzle] " + g.diff.Puzzle
+	}
+	info := fmt.Sprintf("%s  Seed:%d  [%dx%d/%d]  Theme:%s  QMark:%v", label, g.b.seed, g.b.W, g.b.H, g.b.Mines, th.Name, g.allowQuestion)
+	if g.QuickFlagMode {
+		info += "  [QF]"
+	}
+	if g.NoGuess {
+		info += "  [NG]"
+	}
+	if g.b.Toroidal {
+		info += "  [Toroidal]"
+	}
+	if g.b.SymmetricMines {
+		info += "  [SYM]"
+	}
+	if g.b.ClusteredMines {
+		info += "  [CLUSTER]"
+	}
+	if g.practiceMode {
+		info += "  [PRACTICE]"
+	}
+	if g.efficiencyMode {
+		info += "  [EFF]"
+	}
+	if g.flagPenaltyMode {
+		info += "  [FlagPenalty]"
+	}
+	if g.noFlags {
+		info += "  [NoFlags]"
+	}
+	if g.tournament != nil {
+		info += "  " + g.tournamentStatus()
+	}
+	if g.marathonMode {
+		info += "  " + g.marathonStatus()
+	}
+	if g.AntiHintMode {
+		info += "  [Anti-hint]"
+	}
+	if g.showChordable {
+		info += "  [chord-hints]"
+	}
+	if g.whatIf {
+		info += "  [WHAT-IF]"
+	}
+	if g.hintReason != "" {
+		info += "  Hint: " + g.hintReason
+		if g.b.placed {
+			info += fmt.Sprintf("  Islands:%d", g.b.IslandCount())
 		}
 	}
-
-	info := fmt.Sprintf("%s  [%dx%d/%d]  Theme:%s  QMark:%v", g.diff.Name, g.b.W, g.b.H, g.b.Mines, th.Name, g.allowQuestion)
 	text.Draw(screen, info, g.fontMain, outerPadding, 10, th.HeaderTextSoft)
 
+	if g.showCoordinates && !g.paused {
+		mx, my := g.normalizeInputPos(ebiten.CursorPosition())
+		if hx, hy, ok := g.boardPosFromCursor(mx, my); ok {
+			g.drawCellTooltip(screen, hx, hy, th)
+		}
+	}
+
 	if g.paused {
 		drawOverlayPanel(screen, "PAUSED", []string{"Press P to resume"}, th)
 	}
 	if g.showHelp {
 		lines := []string{
-			"N: New game | 1/2/3: Beginner/Intermediate/Expert",
+			"N: New game | R: Retry same board | 1/2/3: Beginner/Intermediate/Expert",
+			"Shift+N: New game from a seed (shown in the top bar)",
+			"Ctrl+Z / U: Undo the last reveal or mark (up to 10)",
+			"Ctrl+S: Save game | Ctrl+L: Load saved game",
+			"D: Daily challenge (Expert, same layout for everyone, one try a day)",
 			"C: Custom board | Enter: Apply custom",
-			"Left click: Reveal / Chord | Right click: Flag/?",
-			"Touch: tap = current mode action | long-press = flag/?",
+			g.mouseHelp(),
+			"Keyboard: Arrows/Tab move | Space: Reveal | F: Flag/? | Enter: Chord",
+			"Shift+Arrows / middle drag: Scroll a board bigger than the window",
+			"G: No-guess mode (never needs a guess; hints are off)",
+			"Shift+Q: Quick flag mode (swaps left/right click and tap/long-press)",
+			"L: Left-handed mouse (right button reveals, left button flags)",
+			"Touch: tap = current mode action | long-press = flag/? | slow drag = flag cells | pinch = zoom",
+			fmt.Sprintf("Long press %dms (default %d, --long-press-ms) | tap slop %dpx (default %d, --touch-slop-px)",
+				touchLongPressDur.Milliseconds(), defaultLongPressMs, touchMoveSlopPx, defaultTouchSlopPx),
 			"Top-right T:OPEN/T:FLAG 버튼으로 터치 모드 전환",
+			"Ctrl+A: Auto-solve every logical move (no record for this game)",
+			"+/-: Bigger / smaller cells (--cell-size N at start)",
+			"Ctrl+= / Ctrl+-: Zoom the board in / out, Ctrl+0: 100%",
 			"H: Hint | P: Pause | T: Theme | S: Scores | Q: Toggle ? marks",
-			"F1: Toggle Help | Click smiley to restart",
+			"Shift+C: Cell coordinates tooltip | Shift+1: Flag obvious mines",
+			"Shift+F: Tick flags confirmed by two or more numbers",
+			"Shift+K: Tint numbers that are ready to chord",
+			"Shift+H: Anti-hint mode (H also outlines the riskiest cell in red)",
+			"Ctrl+Shift+T: Export board as TikZ (--export-tex FILE)",
+			"Ctrl+Shift+S: Export board as SVG (--export-svg FILE)",
+			"Ctrl+Shift+A: Animations on / off",
+			"Ctrl+P / PrintScreen: Save a PNG screenshot",
+			"Ctrl+T: Countdown mode (off / 60s / 120s / 300s, starts a new game)",
+			"A: Flag every mine the numbers force",
+			"W: Toroidal board, edges wrap (from the next layout)",
+			"Shift+S: Point-symmetric mines (from the next layout)",
+			"Shift+I: Clustered mines (from the next layout)",
+			"Ctrl+F: Outline the frontier (hidden cells next to numbers)",
+			"Shift+G: Tint logically linked frontier cells alike",
+			"O: Show each hidden cell's mine chance in percent",
+			"Ctrl+R: Practice mode (unlimited undo, no records)",
+			"Ctrl+Shift+E: Efficiency mode (score by clicks)",
+			"Ctrl+N: No-flags variant (the counter shows mines not yet deduced)",
+			fmt.Sprintf("Shift+P: Wrong-flag penalty (+%ds per wrong flag on a win)", int(flagPenalty.Seconds())),
+			fmt.Sprintf("Ctrl+M: Marathon of %d boards (a loss adds %ds)", marathonBoards, int(marathonPenalty.Seconds())),
+			"Shift+E (after a win): Explain the solution step by step (Up/Down)",
+			"Shift+R: Replays of recent games (Space: pause, F: 2x, Esc: stop)",
+			"Ctrl+E: Board editor (click: mine, Enter: play the layout)",
+			"Ctrl+W: What-if, try opening safe cells then roll back",
+			fmt.Sprintf("Ctrl+G: Tournament of %d seeded rounds, the same for every run today", tournamentRounds),
+			"Ctrl+O: Settings panel | F11: Fullscreen | F1: Toggle Help | Click smiley to restart",
 		}
 		drawOverlayPanel(screen, "HELP", lines, th)
 	}
+	if g.showReplays {
+		drawOverlayPanel(screen, "REPLAYS", g.replayLines(), th)
+	}
+	if g.showMarathon {
+		drawOverlayPanel(screen, "MARATHON", g.marathonLines(), th)
+	}
+	if g.showTournament {
+		drawOverlayPanel(screen, "TOURNAMENT", g.tournamentLines(), th)
+	}
+	if g.showSettings {
+		g.drawSettingsPanel(screen, th)
+	}
 	if g.showScores {
 		lines := g.scoreLines()
-		drawOverlayPanel(screen, "BEST SCORES", lines, th)
+		panel := drawOverlayPanel(screen, "BEST SCORES", lines, th)
+		// the chart is read from the layout, so it waits for the game to end
+		if g.b.placed && g.state != statePlaying {
+			half := panel.Dx() / 2
+			chart := image.Rect(panel.Min.X+half, panel.Min.Y+40, panel.Max.X-10, panel.Max.Y-30)
+			drawDensityChart(screen, chart, g.b.DensityByRing(g.b.start.X, g.b.start.Y), th)
+		}
+		g.swatchRects = swatchRects(panel, len(themes))
+		for i, r := range g.swatchRects {
+			drawThemeSwatch(screen, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), themes[i])
+			if i == g.themeIdx {
+				vector.StrokeRect(screen, float32(r.Min.X-1), float32(r.Min.Y-1), float32(r.Dx()+2), float32(r.Dy()+2), 1, color.White, false)
+			}
+		}
+	} else {
+		g.swatchRects = nil
 	}
 	if g.showCustom {
 		g.drawCustomDialog(screen, th)
 	}
 
+	_, windowH := g.Layout(0, 0)
+	g.drawDebugPanel(screen, 4, windowH-4, th)
+
+	if g.seedPrompt.active {
+		buf := g.seedPrompt.buf
+		if g.cursorBlink {
+			buf += "|"
+		}
+		drawBanner(screen, "NEW GAME FROM SEED", []string{buf, "Enter: start  Esc: cancel"}, th)
+	}
+
+	if g.contextMenu != nil {
+		g.drawContextMenu(screen, th)
+	}
+	if g.toast != "" && time.Now().Before(g.toastUntil) {
+		drawToast(screen, g.toast, th)
+	}
+
+	g.retryRect = image.Rectangle{}
+	if g.playback != nil {
+		g.drawBottomPanel(screen, g.playbackStatus(), th)
+		return
+	}
+	if g.editorMode {
+		g.drawBottomPanel(screen, g.editorStatus(), th)
+		return
+	}
+	if g.whatIf {
+		g.drawBottomPanel(screen, g.whatIfStatus(), th)
+		return
+	}
+	if g.showExplain {
+		g.drawExplainPanel(screen, th)
+		return
+	}
+	if g.practicePending != statePlaying {
+		g.drawPracticePending(screen, th)
+		return
+	}
 	if g.state == stateWon {
-		drawBanner(screen, "YOU WIN!", th)
+		var lines []string
+		if g.paceNote != "" {
+			lines = append(lines, g.paceNote)
+		}
+		lines = append(lines, fmt.Sprintf("%s  3BV %d  3BV/s %.2f", formatDuration(g.elapsed), g.bv3, g.bv3PerSecond()))
+		if g.flagPenaltyMode {
+			lines = append(lines, g.penaltyLine())
+		}
+		if eff := g.clickEfficiency(); eff > 0 {
+			lines = append(lines, fmt.Sprintf("Click efficiency: %.0f%%", eff*100))
+		}
+		if g.lastScore > 0 {
+			lines = append(lines, fmt.Sprintf("Score: %.1f", g.lastScore))
+		}
+		if g.endedQuickFlag {
+			lines = append(lines, "(quick flag mode)")
+		}
+		banner := drawBanner(screen, "YOU WIN!", lines, th)
+		g.drawRetryButton(screen, banner, th)
 	}
 	if g.state == stateLost {
-		drawBanner(screen, "BOOM!", th)
+		var lines []string
+		if g.endedQuickFlag {
+			lines = append(lines, "(quick flag mode)")
+		}
+		banner := drawBanner(screen, fmt.Sprintf("BOOM! Wrong flags: %d", g.wrongFlagsOnLoss), lines, th)
+		g.drawRetryButton(screen, banner, th)
 	}
 }
 
 func (g *game) scoreLines() []string {
-	if len(g.bestScores) == 0 {
-		return []string{"No records yet. Win a game to create one!"}
+	clicks := fmt.Sprintf("This game: left %d  right %d  chord %d", g.leftClicks, g.rightClicks, g.chordClicks)
+	if len(g.bestScores) == 0 && len(g.stats) == 0 {
+		return []string{clicks, "No records yet. Win a game to create one!"}
 	}
-	keys := make([]string, 0, len(g.bestScores))
-	for k := range g.bestScores {
+	keys := make([]string, 0, len(g.stats))
+	for k := range g.stats {
 		keys = append(keys, k)
 	}
-	sort.Strings(keys)
-	lines := make([]string, 0, len(keys)+1)
+	for k := range g.bestScores {
+		if _, ok := g.stats[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sortScoreKeys(keys)
+	lines := make([]string, 0, 2*len(keys)+3)
+	lines = append(lines, clicks)
 	for _, k := range keys {
-		lines = append(lines, fmt.Sprintf("%s : %ds", k, g.bestScores[k]))
+		line := k + " : --:--.--"
+		if e, ok := g.bestScores[k]; ok && e.Clicks > 0 {
+			line = efficiencyLine(k, e)
+		} else if ok {
+			line = fmt.Sprintf("%s : %s%s", k, formatDuration(e.Duration()), e.Mark)
+			if e.NormalisedTimeScore > 0 {
+				line += fmt.Sprintf("  adj: %.0fs", e.NormalisedTimeScore)
+			}
+		}
+		st := g.stats[k]
+		if st != nil && st.BestWeightedScore > 0 {
+			line += fmt.Sprintf("  score %.1f", st.BestWeightedScore)
+		}
+		if st != nil && st.Best3BVPerSec > 0 {
+			line += fmt.Sprintf("  3BV/s %.2f", st.Best3BVPerSec)
+		}
+		lines = append(lines, line)
+		if st == nil {
+			continue
+		}
+		if sum := st.summary(); sum != "" {
+			lines = append(lines, "    "+sum)
+		}
+	}
+	if g.b.placed {
+		lines = append(lines, fmt.Sprintf("Current board 3BV: %d", g.b.Compute3BV()))
 	}
 	lines = append(lines, "(Click or press S to close)")
 	return lines
 }
 
+// customSeedLine is the custom dialog's line for the seed the current
+// board's mines come from.
+func (g *game) customSeedLine() string {
+	return fmt.Sprintf("Current seed: %d  (Ctrl+C: copy)", g.b.seed)
+}
+
 func (g *game) drawCustomDialog(screen *ebiten.Image, th theme) {
 	w, h := g.Layout(0, 0)
-	pw, ph := min(440, w-40), 210
+	pw, ph := min(440, w-40), 236
 	px, py := (w-pw)/2, (h-ph)/2
 	ebitenutil.DrawRect(screen, 0, 0, float64(w), float64(h), th.Overlay)
 	drawSunkenRect(screen, px, py, pw, ph, th)
@@ -909,82 +3601,311 @@ func (g *game) drawCustomDialog(screen *ebiten.Image, th theme) {
 	text.Draw(screen, title, g.fontMain, px+16, py+24, th.HeaderText)
 	text.Draw(screen, "Left/Right: field  Up/Down: value  Enter: start  Esc: cancel", g.fontMain, px+16, py+44, th.HeaderTextSoft)
 
-	labels := []string{"Width", "Height", "Mines"}
-	values := []int{g.custom.W, g.custom.H, g.custom.Mines}
-	for i := 0; i < 3; i++ {
-		x := px + 24 + i*130
+	labels := []string{"Width", "Height", "Mines", "Chord", "Cascade", "Safe"}
+	cascade := "8-way"
+	if g.custom.Connectivity == Connectivity4 {
+		cascade = "4-way"
+	}
+	values := []string{
+		fmt.Sprintf("%d", g.custom.W),
+		fmt.Sprintf("%d", g.custom.H),
+		fmt.Sprintf("%d", g.custom.Mines),
+		fmt.Sprintf("%+d", g.ChordThreshold),
+		cascade,
+		safeZoneLabel(g.SafeZone),
+	}
+	for i := 0; i < len(labels); i++ {
+		x := px + 24 + i*68
 		y := py + 96
 		label := labels[i]
-		val := fmt.Sprintf("%d", values[i])
+		val := values[i]
 		if g.custom.field == i {
 			label = "> " + label
+			if g.cursorBlink {
+				val += "|"
+			}
 		}
 		text.Draw(screen, label, g.fontMain, x, y, th.HeaderText)
 		text.Draw(screen, val, g.fontMain, x+18, y+28, th.Accent)
 	}
 
+	if g.ChordThreshold < 0 {
+		text.Draw(screen, "Chord < 0 is risky: may open unflagged mines", g.fontMain, px+16, py+150, color.RGBA{210, 40, 40, 255})
+	}
+
 	maxM := g.custom.W*g.custom.H - 1
-	text.Draw(screen, fmt.Sprintf("Max mines: %d", maxM), g.fontMain, px+16, py+170, th.HeaderTextSoft)
+	bv := "-"
+	if g.b.placed {
+		bv = strconv.Itoa(g.b.Compute3BV())
+	}
+	text.Draw(screen, fmt.Sprintf("Max mines: %d   Current board 3BV: %s", maxM, bv), g.fontMain, px+16, py+170, th.HeaderTextSoft)
+
+	text.Draw(screen, g.customSeedLine(), g.fontMain, px+16, py+192, th.HeaderTextSoft)
+	next := "Next seed: random  (Ctrl+S: set)"
+	switch {
+	case g.custom.seedInput.active:
+		next = "Next seed: " + g.custom.seedInput.buf
+		if g.cursorBlink {
+			next += "|"
+		}
+		next += "  (Enter: ok  Esc: cancel)"
+	case g.custom.hasSeed:
+		next = fmt.Sprintf("Next seed: %d  (Ctrl+S: change)", g.custom.Seed)
+	}
+	text.Draw(screen, next, g.fontMain, px+16, py+212, th.HeaderText)
 }
 
 func (g *game) drawCell(screen *ebiten.Image, x, y int, th theme) {
+	if g.editorMode {
+		g.drawEditorCell(screen, x, y, th)
+		return
+	}
 	c := g.b.cells[y][x]
-	px := outerPadding + x*cellSize
-	py := topPanelHeight + y*cellSize
+	px, py := g.cellOrigin(x, y)
+	cs, fs := float64(cellSizePx), float32(cellSizePx)
 
-	if c.Revealed {
-		ebitenutil.DrawRect(screen, float64(px), float64(py), cellSize, cellSize, th.CellRevealed)
-		vector.StrokeRect(screen, float32(px), float32(py), cellSize, cellSize, 1, th.CellGrid, false)
+	if c.Revealed() {
+		bg := th.CellRevealed
+		if g.whatIfCells[[2]int{x, y}] {
+			bg = rgb(250, 230, 120)
+		}
+		ebitenutil.DrawRect(screen, float64(px), float64(py), cs, cs, bg)
+		vector.StrokeRect(screen, float32(px), float32(py), fs, fs, 1, th.CellGrid, false)
 
 		if c.Mine {
 			mineColor := th.Mine
-			if c.Exploded {
-				ebitenutil.DrawRect(screen, float64(px), float64(py), cellSize, cellSize, color.RGBA{210, 40, 40, 255})
+			if c.Exploded() {
+				ebitenutil.DrawRect(screen, float64(px), float64(py), cs, cs, color.RGBA{210, 40, 40, 255})
 				mineColor = color.RGBA{0, 0, 0, 255}
 			}
-			vector.DrawFilledCircle(screen, float32(px+cellSize/2), float32(py+cellSize/2), 6, mineColor, false)
+			vector.DrawFilledCircle(screen, float32(px+cellSizePx/2), float32(py+cellSizePx/2), fs/4, mineColor, false)
 			return
 		}
 
 		if c.Adjacent > 0 {
-			col := numberColors[c.Adjacent]
-			if g.themeIdx == 1 && c.Adjacent == 1 {
-				col = rgb(120, 170, 255)
+			col := th.NumberColors[c.Adjacent]
+			if th.Accessible {
+				drawNumberShape(screen, px, py, c.Adjacent, withAlpha(col, 110))
+			}
+			drawTextCentered(screen, fmt.Sprintf("%d", c.Adjacent), g.fontMain, px, py+(cellSizePx-13)/2, cellSizePx, col)
+			// mines return above, so the exploded cell is never tinted
+			if g.showChordable && g.b.countAdjacentFlags(x, y) == c.Adjacent {
+				ebitenutil.DrawRect(screen, float64(px), float64(py), cs, cs, withAlpha(th.Accent, 60))
 			}
-			drawTextCentered(screen, fmt.Sprintf("%d", c.Adjacent), g.fontMain, px, py+5, cellSize, col)
 		}
-		if c.WrongFlag {
-			vector.StrokeLine(screen, float32(px+4), float32(py+4), float32(px+cellSize-4), float32(py+cellSize-4), 2, th.WrongFlag, false)
-			vector.StrokeLine(screen, float32(px+cellSize-4), float32(py+4), float32(px+4), float32(py+cellSize-4), 2, th.WrongFlag, false)
+		if f, ok := g.fading[[2]int{x, y}]; ok {
+			t := easeOutSine(float64(max(f, 0)) / revealFadeFrames)
+			ebitenutil.DrawRect(screen, float64(px), float64(py), cs, cs, withAlpha(th.CellHidden, uint8((1-t)*255)))
 		}
 		return
 	}
 
 	// Hidden
-	drawRaisedRect(screen, px, py, cellSize, cellSize, th)
+	if cellSizePx >= shadowMinCellSize {
+		ebitenutil.DrawRect(screen, float64(px+1), float64(py+1), cs, cs, blendColor(th.BG, th.Dark, 0.5))
+	}
+	drawRaisedRect(screen, px, py, cellSizePx, cellSizePx, th)
+	if g.groupOf != nil && g.groupOf[y][x] > 0 {
+		tint := groupPalette[(g.groupOf[y][x]-1)%len(groupPalette)]
+		ebitenutil.DrawRect(screen, float64(px+2), float64(py+2), cs-4, cs-4, withAlpha(tint, 90))
+	}
 
-	if c.Flagged {
-		vector.DrawFilledRect(screen, float32(px+11), float32(py+6), 2, 12, th.CellText, false)
-		vector.StrokeLine(screen, float32(px+11), float32(py+6), float32(px+5), float32(py+10), 1.5, th.Flag, false)
-		vector.StrokeLine(screen, float32(px+5), float32(py+10), float32(px+11), float32(py+14), 1.5, th.Flag, false)
-		vector.StrokeLine(screen, float32(px+11), float32(py+6), float32(px+11), float32(py+14), 1.5, th.Flag, false)
-		vector.DrawFilledRect(screen, float32(px+8), float32(py+8), 3, 4, th.Flag, false)
-		vector.DrawFilledRect(screen, float32(px+7), float32(py+17), 9, 2, th.CellText, false)
-	} else if c.Question {
-		drawTextCentered(screen, "?", g.fontMain, px, py+5, cellSize, th.CellText)
+	if c.Flagged() && c.WrongFlag() {
+		// revealAllMines leaves wrong flags hidden: show the flag dimmed
+		// under a cross so the mistake is easy to find
+		drawFlag(screen, px, py, th)
+		ebitenutil.DrawRect(screen, float64(px+2), float64(py+2), cs-4, cs-4, withAlpha(th.CellHidden, 150))
+		vector.StrokeLine(screen, float32(px+4), float32(py+4), float32(px+cellSizePx-4), float32(py+cellSizePx-4), 2, th.WrongFlag, false)
+		vector.StrokeLine(screen, float32(px+cellSizePx-4), float32(py+4), float32(px+4), float32(py+cellSizePx-4), 2, th.WrongFlag, false)
+	} else if c.Flagged() {
+		drawFlag(screen, px, py, th)
+		if g.showCorroborated && g.b.isCorroborated(x, y) {
+			ebitenutil.DrawRect(screen, float64(px+2), float64(py+2), cs-4, cs-4, withAlpha(th.CellHidden, 110))
+			drawTick(screen, px+cellSizePx-10, py+2, min(7, cellSizePx/3), th.Accent)
+		}
+	} else if c.Question() {
+		drawTextCentered(screen, "?", g.fontMain, px, py+(cellSizePx-13)/2, cellSizePx, th.CellText)
+	} else if g.heatmap != nil {
+		// a percentage without the sign; three digits still fit the
+		// smallest cell
+		p := g.heatmap[y][x]
+		drawTextCentered(screen, fmt.Sprintf("%.0f", p*100), g.fontMain, px, py+(cellSizePx-13)/2, cellSizePx, blendColor(rgb(40, 150, 60), rgb(210, 30, 30), p))
+	}
+	if c.Locked {
+		drawPadlock(screen, px, py, th)
+	}
+	if g.frontier != nil && g.frontier[y][x] {
+		vector.StrokeRect(screen, float32(px+1), float32(py+1), fs-2, fs-2, 1, withAlpha(th.Accent, 150), false)
 	}
 
 	if g.hint != nil && g.hint.X == x && g.hint.Y == y && g.state == statePlaying {
-		vector.StrokeRect(screen, float32(px+2), float32(py+2), cellSize-4, cellSize-4, 2, th.Accent, false)
+		w, a := g.hintPulseStroke()
+		vector.StrokeRect(screen, float32(px+2), float32(py+2), fs-4, fs-4, w, withAlpha(rgb(40, 170, 60), a), false)
+	}
+	if g.antiHint != nil && g.antiHint.X == x && g.antiHint.Y == y && g.state == statePlaying {
+		vector.StrokeRect(screen, float32(px+2), float32(py+2), fs-4, fs-4, 2, rgb(220, 40, 40), false)
+	}
+}
+
+// drawExplainHighlights outlines the cells of the current deduction step:
+// the numbers it reads in blue, cells it opens in green, mines it finds in red.
+func (g *game) drawExplainHighlights(screen *ebiten.Image) {
+	step := g.explain[g.explainIdx]
+	outline := func(x, y int, clr color.Color) {
+		px, py := g.cellOrigin(x, y)
+		vector.StrokeRect(screen, float32(px+2), float32(py+2), float32(cellSizePx-4), float32(cellSizePx-4), 2, clr, false)
+	}
+	for _, p := range step.Sources {
+		outline(p.X, p.Y, rgb(40, 90, 220))
+	}
+	for _, a := range step.Actions {
+		if a.Kind == ActionFlag {
+			outline(a.X, a.Y, rgb(220, 40, 40))
+		} else {
+			outline(a.X, a.Y, rgb(40, 170, 60))
+		}
+	}
+}
+
+// drawExplainPanel shows the current deduction at the bottom of the window,
+// wrapped to fit narrow boards.
+func (g *game) drawExplainPanel(screen *ebiten.Image, th theme) {
+	w := screen.Bounds().Dx()
+	lines := []string{fmt.Sprintf("Step %d/%d  (Up/Down, Esc)", g.explainIdx+1, len(g.explain))}
+	lines = append(lines, wrapText(g.explain[g.explainIdx].Rule, g.fontMain, w-24)...)
+	g.drawBottomPanel(screen, lines, th)
+}
+
+// drawBottomPanel draws a strip along the bottom of the window; the first
+// line is the dimmer heading.
+func (g *game) drawBottomPanel(screen *ebiten.Image, lines []string, th theme) {
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+	ph := 10 + len(lines)*16
+	py := h - ph - 4
+	ebitenutil.DrawRect(screen, 4, float64(py), float64(w-8), float64(ph), withAlpha(th.Panel, 230))
+	vector.StrokeRect(screen, 4, float32(py), float32(w-8), float32(ph), 1, th.Dark, false)
+	for i, ln := range lines {
+		clr := th.HeaderText
+		if i == 0 {
+			clr = th.HeaderTextSoft
+		}
+		text.Draw(screen, ln, g.fontMain, 12, py+18+i*16, clr)
+	}
+}
+
+// tooltipLabel is the hovered cell's tooltip text. The number of a hidden
+// cell stays "?" outside practice mode until the game is over, so the
+// tooltip cannot be used to peek at the board.
+func (g *game) tooltipLabel(hx, hy int) string {
+	c := g.b.cells[hy][hx]
+	known := c.Revealed() || (g.b.placed && (g.state != statePlaying || g.practiceMode))
+	adj := "?"
+	switch {
+	case known && c.Mine:
+		adj = "mine"
+	case known:
+		adj = fmt.Sprintf("%d", c.Adjacent)
+	}
+	return fmt.Sprintf("(%d,%d) adj:%s", hx, hy, adj)
+}
+
+// drawCellTooltip labels the hovered cell with its coordinates and number.
+func (g *game) drawCellTooltip(screen *ebiten.Image, hx, hy int, th theme) {
+	c := g.b.cells[hy][hx]
+	label := g.tooltipLabel(hx, hy)
+
+	tipW := text.BoundString(g.fontMain, label).Dx() + 8
+	if c.Revealed() {
+		tipW += 12
+	}
+	tipH := 18
+	sw, sh := screen.Bounds().Dx(), screen.Bounds().Dy()
+	px, py := g.cellOrigin(hx, hy)
+	x, y := tooltipPos(px, py, tipW, tipH, sw, sh)
+	ebitenutil.DrawRect(screen, float64(x), float64(y), float64(tipW), float64(tipH), th.Panel)
+	vector.StrokeRect(screen, float32(x), float32(y), float32(tipW), float32(tipH), 1, th.Dark, false)
+	text.Draw(screen, label, g.fontMain, x+4, y+13, th.HeaderText)
+	if c.Revealed() {
+		drawTick(screen, x+tipW-13, y+4, 9, th.Accent)
+	}
+}
+
+// tooltipPos places a w×h tooltip two cells right and one cell below the
+// hovered cell at (px,py), pulled back inside the screen when it would
+// overflow.
+func tooltipPos(px, py, w, h, screenW, screenH int) (int, int) {
+	x := px + 2*cellSizePx
+	y := py + cellSizePx
+	x = clamp(x, 0, max(screenW-w, 0))
+	y = clamp(y, 0, max(screenH-h, 0))
+	return x, y
+}
+
+// drawNumberShape outlines the shape that stands for n behind a number: a
+// circle for 1, a square for 2, and a regular polygon with n sides above.
+func drawNumberShape(screen *ebiten.Image, px, py, n int, clr color.Color) {
+	cx, cy := float32(px)+float32(cellSizePx)/2, float32(py)+float32(cellSizePx)/2
+	r := float32(cellSizePx)/2 - 2
+	switch n {
+	case 1:
+		vector.StrokeCircle(screen, cx, cy, r, 1.5, clr, true)
+	case 2:
+		vector.StrokeRect(screen, cx-r*0.8, cy-r*0.8, r*1.6, r*1.6, 1.5, clr, false)
+	default:
+		prev := polygonPoint(cx, cy, r, n, 0)
+		for i := 1; i <= n; i++ {
+			next := polygonPoint(cx, cy, r, n, i)
+			vector.StrokeLine(screen, prev[0], prev[1], next[0], next[1], 1.5, clr, true)
+			prev = next
+		}
 	}
 }
 
-func drawOverlayPanel(screen *ebiten.Image, title string, lines []string, th theme) {
+// polygonPoint returns vertex i of a regular n-gon of radius r around
+// (cx, cy), with vertex 0 at the top.
+func polygonPoint(cx, cy, r float32, n, i int) [2]float32 {
+	a := 2*math.Pi*float64(i)/float64(n) - math.Pi/2
+	return [2]float32{cx + r*float32(math.Cos(a)), cy + r*float32(math.Sin(a))}
+}
+
+// drawFloppy draws a size×size floppy disk: body, metal shutter and label.
+func drawFloppy(screen *ebiten.Image, x, y, size int, th theme) {
+	fx, fy, fs := float32(x), float32(y), float32(size)
+	vector.DrawFilledRect(screen, fx, fy, fs, fs, th.Accent, false)
+	vector.DrawFilledRect(screen, fx+fs*0.25, fy, fs*0.5, fs*0.35, th.Light, false)
+	vector.DrawFilledRect(screen, fx+fs*0.15, fy+fs*0.55, fs*0.7, fs*0.45, color.White, false)
+}
+
+func drawTick(screen *ebiten.Image, x, y, size int, clr color.Color) {
+	fx, fy, fs := float32(x), float32(y), float32(size)
+	vector.StrokeLine(screen, fx, fy+fs*0.55, fx+fs*0.35, fy+fs, 2, clr, false)
+	vector.StrokeLine(screen, fx+fs*0.35, fy+fs, fx+fs, fy, 2, clr, false)
+}
+
+// drawFlag is laid out on a defaultCellSize cell and scaled to cellSizePx.
+func drawFlag(screen *ebiten.Image, px, py int, th theme) {
+	x, y, s := float32(px), float32(py), float32(cellSizePx)/defaultCellSize
+	vector.DrawFilledRect(screen, x+11*s, y+6*s, 2*s, 12*s, th.CellText, false)
+	vector.StrokeLine(screen, x+11*s, y+6*s, x+5*s, y+10*s, 1.5, th.Flag, false)
+	vector.StrokeLine(screen, x+5*s, y+10*s, x+11*s, y+14*s, 1.5, th.Flag, false)
+	vector.StrokeLine(screen, x+11*s, y+6*s, x+11*s, y+14*s, 1.5, th.Flag, false)
+	vector.DrawFilledRect(screen, x+8*s, y+8*s, 3*s, 4*s, th.Flag, false)
+	vector.DrawFilledRect(screen, x+7*s, y+17*s, 9*s, 2*s, th.CellText, false)
+}
+
+// drawOverlayPanel draws a modal panel and returns its inner rectangle so
+// callers can add graphics below the text.
+func drawOverlayPanel(screen *ebiten.Image, title string, lines []string, th theme) image.Rectangle {
 	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
 	ebitenutil.DrawRect(screen, 0, 0, float64(w), float64(h), th.Overlay)
 	pw := min(560, w-36)
-	ph := min(280, h-36)
+	ph := min(max(280, 68+len(lines)*20), h-36)
 	px, py := (w-pw)/2, (h-ph)/2
+	// squeeze the line spacing before dropping lines on short windows
+	step := 20
+	if len(lines) > 0 {
+		step = clamp((ph-68)/len(lines), 14, 20)
+	}
 	drawSunkenRect(screen, px, py, pw, ph, th)
 	ebitenutil.DrawRect(screen, float64(px+6), float64(py+6), float64(pw-12), float64(ph-12), th.Panel)
 
@@ -993,18 +3914,114 @@ func drawOverlayPanel(screen *ebiten.Image, title string, lines []string, th the
 	y := py + 50
 	for _, ln := range lines {
 		text.Draw(screen, ln, ff, px+16, y, th.HeaderText)
-		y += 20
+		y += step
 		if y > py+ph-18 {
 			break
 		}
 	}
+	return image.Rect(px+6, py+6, px+pw-6, py+ph-6)
+}
+
+// Theme swatches sit in a row at the bottom right of the scores panel.
+const (
+	swatchW   = 20
+	swatchH   = 12
+	swatchGap = 6
+)
+
+// swatchRects lays out n theme swatches right-aligned along the bottom of
+// the panel's inner rectangle.
+func swatchRects(panel image.Rectangle, n int) []image.Rectangle {
+	out := make([]image.Rectangle, n)
+	x := panel.Max.X - 10 - n*swatchW - (n-1)*swatchGap
+	y := panel.Max.Y - 10 - swatchH
+	for i := range out {
+		out[i] = image.Rect(x, y, x+swatchW, y+swatchH)
+		x += swatchW + swatchGap
+	}
+	return out
+}
+
+// drawThemeSwatch previews a theme as four horizontal bands: hidden cell,
+// revealed cell, accent and flag colours.
+func drawThemeSwatch(screen *ebiten.Image, x, y, w, h int, th theme) {
+	bands := []color.Color{th.CellHidden, th.CellRevealed, th.Accent, th.Flag}
+	for i, clr := range bands {
+		y0 := y + i*h/len(bands)
+		y1 := y + (i+1)*h/len(bands)
+		ebitenutil.DrawRect(screen, float64(x), float64(y0), float64(w), float64(y1-y0), clr)
+	}
+}
+
+// drawDensityChart draws one horizontal bar per ring, scaled so that the
+// densest ring fills the chart width.
+func drawDensityChart(screen *ebiten.Image, r image.Rectangle, density []float64, th theme) {
+	ff := basicfont.Face7x13
+	peak := 0.0
+	for _, d := range density {
+		peak = math.Max(peak, d)
+	}
+	text.Draw(screen, fmt.Sprintf("Density by ring (max %.0f%%)", peak*100), ff, r.Min.X, r.Min.Y, th.HeaderTextSoft)
+	y := r.Min.Y + 8
+	for _, d := range density {
+		if y+4 > r.Max.Y {
+			break
+		}
+		ebitenutil.DrawRect(screen, float64(r.Min.X), float64(y), float64(r.Dx()), 4, th.Dark)
+		if peak > 0 {
+			ebitenutil.DrawRect(screen, float64(r.Min.X), float64(y), float64(r.Dx())*d/peak, 4, th.Accent)
+		}
+		y += 6
+	}
+}
+
+func (g *game) drawRetryButton(screen *ebiten.Image, banner image.Rectangle, th theme) {
+	bw, bh := 60, 18
+	x, y := banner.Min.X+(banner.Dx()-bw)/2, banner.Max.Y+4
+	g.retryRect = image.Rect(x, y, x+bw, y+bh)
+	drawRaisedRect(screen, x, y, bw, bh, th)
+	drawTextCentered(screen, "Retry", basicfont.Face7x13, x, y+3, bw, th.HeaderText)
 }
 
-func drawBanner(screen *ebiten.Image, label string, th theme) {
+func drawBanner(screen *ebiten.Image, label string, lines []string, th theme) image.Rectangle {
 	w := screen.Bounds().Dx()
-	bh := 30
+	bh := 30 + len(lines)*16
 	ebitenutil.DrawRect(screen, float64((w-220)/2), 14, 220, float64(bh), th.Overlay)
 	drawTextCentered(screen, label, basicfont.Face7x13, (w-220)/2, 22, 220, th.Accent)
+	for i, ln := range lines {
+		drawTextCentered(screen, ln, basicfont.Face7x13, (w-220)/2, 38+i*16, 220, th.Accent)
+	}
+	return image.Rect((w-220)/2, 14, (w-220)/2+220, 14+bh)
+}
+
+func drawToast(screen *ebiten.Image, msg string, th theme) {
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+	ff := basicfont.Face7x13
+	tw := min(text.BoundString(ff, msg).Dx()+20, w-8)
+	x, y := (w-tw)/2, h-outerPadding-28
+	ebitenutil.DrawRect(screen, float64(x), float64(y), float64(tw), 22, th.Overlay)
+	drawTextCentered(screen, msg, ff, x, y+3, tw, th.Accent)
+}
+
+// wrapText splits s on spaces into lines no wider than maxW.
+func wrapText(s string, face font.Face, maxW int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		next := word
+		if line != "" {
+			next = line + " " + word
+		}
+		if line != "" && text.BoundString(face, next).Dx() > maxW {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
 }
 
 func drawRaisedRect(screen *ebiten.Image, x, y, w, h int, th theme) {
@@ -1029,18 +4046,27 @@ func drawTextCentered(screen *ebiten.Image, s string, f font.Face, x, y, w int,
 	text.Draw(screen, s, f, x+(w-tw)/2, y+13, clr)
 }
 
+// segScale sizes the seven-segment digits with the cells. They only shrink:
+// the top panel keeps its height, and full size is the most that fits it.
+func segScale() float64 {
+	return min(1, float64(cellSizePx)/defaultCellSize)
+}
+
+// segDigitW is the advance of one seven-segment digit.
+func segDigitW() int {
+	return int(18 * segScale())
+}
+
 func drawDigital(screen *ebiten.Image, x, y, value, digits int, clr color.Color) {
 	// Box
-	ebitenutil.DrawRect(screen, float64(x-3), float64(y-3), float64(digits*18+6), 28, color.RGBA{20, 20, 20, 255})
+	ebitenutil.DrawRect(screen, float64(x-3), float64(y-3), float64(digits*segDigitW()+6), 28*segScale(), color.RGBA{20, 20, 20, 255})
 
 	n := value
 	neg := n < 0
 	if neg {
 		n = -n
 	}
-	if n > int(math.Pow10(digits))-1 {
-		n = int(math.Pow10(digits)) - 1
-	}
+	n = min(n, int(math.Pow10(digits))-1)
 
 	chars := make([]int, digits)
 	for i := digits - 1; i >= 0; i-- {
@@ -1051,7 +4077,38 @@ func drawDigital(screen *ebiten.Image, x, y, value, digits int, clr color.Color)
 		chars[0] = -1 // minus
 	}
 	for i := 0; i < digits; i++ {
-		drawSevenSegDigit(screen, x+i*18, y, chars[i], clr)
+		drawSevenSegDigit(screen, x+i*segDigitW(), y, chars[i], clr)
+	}
+}
+
+// The MM:SS.cc timer is six digits plus two narrow separators; the window
+// must be at least digitalMsMinWindowW wide to keep it clear of the face.
+const digitalMsMinWindowW = 330
+
+func digitalMsWidth() int {
+	return 6*segDigitW() + 2*int(6*segScale())
+}
+
+// drawDigitalMs is drawDigital for a duration: MM:SS.cc, two digits each,
+// with a colon and a decimal point between the pairs.
+func drawDigitalMs(screen *ebiten.Image, x, y int, d time.Duration, clr color.Color) {
+	k := segScale()
+	ebitenutil.DrawRect(screen, float64(x-3), float64(y-3), float64(digitalMsWidth()+6), 28*k, color.RGBA{20, 20, 20, 255})
+	s := formatDuration(d)
+	cx := x
+	for _, r := range s {
+		switch r {
+		case ':':
+			ebitenutil.DrawRect(screen, float64(cx)+2*k, float64(y)+6*k, 2*k, 2*k, clr)
+			ebitenutil.DrawRect(screen, float64(cx)+2*k, float64(y)+16*k, 2*k, 2*k, clr)
+			cx += int(6 * k)
+		case '.':
+			ebitenutil.DrawRect(screen, float64(cx)+2*k, float64(y)+22*k, 2*k, 2*k, clr)
+			cx += int(6 * k)
+		default:
+			drawSevenSegDigit(screen, cx, y, int(r-'0'), clr)
+			cx += segDigitW()
+		}
 	}
 }
 
@@ -1078,7 +4135,9 @@ func drawSevenSegDigit(screen *ebiten.Image, x, y, d int, clr color.Color) {
 	}
 
 	off := color.RGBA{60, 20, 20, 255}
+	k := segScale()
 	seg := func(on bool, rx, ry, rw, rh float64) {
+		rx, ry, rw, rh = rx*k, ry*k, rw*k, rh*k
 		if on {
 			ebitenutil.DrawRect(screen, float64(x)+rx, float64(y)+ry, rw, rh, clr)
 		} else {
@@ -1095,19 +4154,81 @@ func drawSevenSegDigit(screen *ebiten.Image, x, y, d int, clr color.Color) {
 	seg(mask&0b0000001 != 0, 3, 11, 10, 2) // g
 }
 
+// keyJustPressed reports a press of k with no modifier held, so plain
+// shortcuts do not also fire for their Shift/Ctrl variants.
+func keyJustPressed(k ebiten.Key) bool {
+	return inpututil.IsKeyJustPressed(k) && !shiftHeld() && !ctrlHeld() && !altHeld()
+}
+
+// keyRepeated is keyJustPressed with auto-repeat while the key is held.
+func keyRepeated(k ebiten.Key) bool {
+	if shiftHeld() || ctrlHeld() || altHeld() {
+		return false
+	}
+	return repeatTick(inpututil.KeyPressDuration(k))
+}
+
+// shiftKeyRepeated is keyRepeated for Shift+k.
+func shiftKeyRepeated(k ebiten.Key) bool {
+	if !shiftHeld() || ctrlHeld() || altHeld() {
+		return false
+	}
+	return repeatTick(inpututil.KeyPressDuration(k))
+}
+
+// repeatTick reports whether a key held for d ticks fires this tick.
+func repeatTick(d int) bool {
+	return d == 1 || (d >= keyRepeatDelay && (d-keyRepeatDelay)%keyRepeatInterval == 0)
+}
+
+func shiftKeyJustPressed(k ebiten.Key) bool {
+	return inpututil.IsKeyJustPressed(k) && shiftHeld() && !ctrlHeld() && !altHeld()
+}
+
+func ctrlKeyJustPressed(k ebiten.Key) bool {
+	return inpututil.IsKeyJustPressed(k) && ctrlHeld() && !shiftHeld() && !altHeld()
+}
+
+func ctrlShiftKeyJustPressed(k ebiten.Key) bool {
+	return inpututil.IsKeyJustPressed(k) && ctrlHeld() && shiftHeld() && !altHeld()
+}
+
+func shiftHeld() bool {
+	return ebiten.IsKeyPressed(ebiten.KeyShift)
+}
+
+func ctrlHeld() bool {
+	return ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+}
+
+func altHeld() bool {
+	return ebiten.IsKeyPressed(ebiten.KeyAlt)
+}
+
 func rgb(r, g, b uint8) color.Color {
 	return color.RGBA{R: r, G: g, B: b, A: 255}
 }
 
-func pointInRect(x, y int, r image.Rectangle) bool {
-	return x >= r.Min.X && x <= r.Max.X && y >= r.Min.Y && y <= r.Max.Y
+// blendColor mixes fg over bg with the given opacity, working on the
+// premultiplied 16-bit channels that color.Color exposes.
+func blendColor(bg, fg color.Color, alpha float64) color.Color {
+	br, bgr, bb, ba := bg.RGBA()
+	fr, fgr, fb, fa := fg.RGBA()
+	mix := func(b, f uint32) uint8 {
+		return uint8((float64(b)*(1-alpha) + float64(f)*alpha) / 257)
+	}
+	return color.RGBA{R: mix(br, fr), G: mix(bgr, fgr), B: mix(bb, fb), A: mix(ba, fa)}
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+// withAlpha returns clr with its alpha replaced by a.
+func withAlpha(clr color.Color, a uint8) color.Color {
+	c := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	c.A = a
+	return c
+}
+
+func pointInRect(x, y int, r image.Rectangle) bool {
+	return x >= r.Min.X && x <= r.Max.X && y >= r.Min.Y && y <= r.Max.Y
 }
 
 func absInt(v int) int {
@@ -1127,43 +4248,220 @@ func clamp(v, lo, hi int) int {
 	return v
 }
 
-func scoreFilePath() string {
+func configFilePath(name string) string {
 	dir, err := os.UserConfigDir()
 	if err != nil {
-		return "minesweeper_scores.json"
+		return "minesweeper_" + name
 	}
 	base := filepath.Join(dir, "go-minesweeper")
 	_ = os.MkdirAll(base, 0o755)
-	return filepath.Join(base, "scores.json")
+	return filepath.Join(base, name)
 }
 
-func loadScores() map[string]int {
-	path := scoreFilePath()
-	data, err := os.ReadFile(path)
+func scoreFilePath() string {
+	return configFilePath("scores.json")
+}
+
+// standardDensity is Intermediate's mine density (40 mines on 16x16), the
+// reference point for normalised times.
+const standardDensity = 0.156
+
+// minRecordTime is the shortest time a record can hold.
+const minRecordTime = 10 * time.Millisecond
+
+// ScoreEntry is one best-time record.
+type ScoreEntry struct {
+	DurationNs int64 `json:"duration_ns"`
+	// Seconds is the whole-second time written by older versions; loadScores
+	// moves it into DurationNs.
+	Seconds int `json:"seconds,omitempty"`
+	// NormalisedTimeScore scales the time by standardDensity over the board's
+	// own density, so records on sparse and dense boards can be compared.
+	NormalisedTimeScore float64 `json:"normalised_time_score"`
+	// Seed is the layout seed of the game that set the record.
+	Seed int64 `json:"seed,omitempty"`
+	// Clicks is the score of an efficiency mode record, which ranks by
+	// clicks first and time second.
+	Clicks int `json:"clicks,omitempty"`
+	// Mark is assistedMark on a record set with the help of hints.
+	Mark string `json:"mark,omitempty"`
+}
+
+func (e ScoreEntry) Duration() time.Duration {
+	return time.Duration(e.DurationNs)
+}
+
+// normalisedTime scales a win time by how dense the board was relative to
+// standardDensity.
+func normalisedTime(seconds float64, w, h, mines int) float64 {
+	if w*h == 0 || mines == 0 {
+		return 0
+	}
+	density := float64(mines) / float64(w*h)
+	return seconds * (standardDensity / density)
+}
+
+// formatDuration renders d as MM:SS.cc, the same layout drawDigitalMs uses.
+func formatDuration(d time.Duration) string {
+	cs := min(int(d/(10*time.Millisecond)), 99*6000+59*100+99)
+	return fmt.Sprintf("%02d:%02d.%02d", cs/6000, cs/100%60, cs%100)
+}
+
+// loadScores reads the best-time file. Older files stored whole seconds,
+// either as the plain value per key or in a "seconds" field; both are
+// converted to nanoseconds, and plain values get a normalised time from the
+// key.
+func loadScores() map[string]ScoreEntry {
+	out := map[string]ScoreEntry{}
+	data, err := os.ReadFile(scoreFilePath())
 	if err != nil {
-		return map[string]int{}
+		return out
 	}
-	var out map[string]int
-	if err := json.Unmarshal(data, &out); err != nil || out == nil {
-		return map[string]int{}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return out
+	}
+	for k, v := range raw {
+		var e ScoreEntry
+		if err := json.Unmarshal(v, &e); err == nil {
+			if e.DurationNs == 0 && e.Seconds > 0 {
+				e.DurationNs = int64(time.Duration(e.Seconds) * time.Second)
+			}
+			e.Seconds = 0
+			out[k] = e
+			continue
+		}
+		var secs int
+		if err := json.Unmarshal(v, &secs); err != nil {
+			continue
+		}
+		e = ScoreEntry{DurationNs: int64(time.Duration(secs) * time.Second)}
+		var name string
+		var w, h, mines int
+		if _, err := fmt.Sscanf(strings.ReplaceAll(k, "_", " "), "%s %dx%d %d", &name, &w, &h, &mines); err == nil {
+			e.NormalisedTimeScore = normalisedTime(float64(secs), w, h, mines)
+		}
+		out[k] = e
 	}
 	return out
 }
 
-func saveScores(scores map[string]int) {
+// Shutdown writes everything the game keeps on disk and returns the first
+// error, after trying every step.
+func (g *game) Shutdown() error {
+	g.cancelPlacement()
+	var first error
+	for _, save := range []func() error{
+		func() error { return saveScores(g.bestScores) },
+		func() error { return saveStats(g.stats) },
+		g.telemetry.Flush,
+		func() error {
+			if g.exitWindow == nil {
+				return nil
+			}
+			return saveWindowState(*g.exitWindow)
+		},
+	} {
+		if err := save(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// quitOnSignal makes Ctrl+C in the terminal or a SIGTERM end the loop
+// through Update, so the records are still written before the process
+// exits. The returned func stops listening.
+func (g *game) quitOnSignal() func() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		g.quit.Store(true)
+	}()
+	return stop
+}
+
+func saveScores(scores map[string]ScoreEntry) error {
 	path := scoreFilePath()
 	data, err := json.MarshalIndent(scores, "", "  ")
 	if err != nil {
-		return
+		return err
 	}
-	_ = os.WriteFile(path, data, 0o644)
+	return os.WriteFile(path, data, 0o644)
 }
 
 func main() {
+	loadConfig().apply()
+	longPressMs := int(touchLongPressDur / time.Millisecond)
+	flag.IntVar(&longPressMs, "long-press-ms", longPressMs, fmt.Sprintf("touch long-press time in ms (default %d, or long_press_ms in config.json)", defaultLongPressMs))
+	flag.IntVar(&touchMoveSlopPx, "touch-slop-px", touchMoveSlopPx, fmt.Sprintf("how far a tap may move in pixels (default %d, or touch_slop_px in config.json)", defaultTouchSlopPx))
+	headless := flag.Bool("headless", false, "play moves from stdin (reveal X Y, flag X Y, chord X Y, reset, svg) and print the board after each, without a window")
+	benchmark := flag.Int("benchmark", 0, "lay out and solve N Expert boards, print the throughput and exit")
+	flag.BoolVar(&noTelemetry, "no-telemetry", false, "do not write telemetry.jsonl (local play statistics) in the config dir")
+	flag.StringVar(&texExportPath, "export-tex", "", "file written by Ctrl+Shift+T (default: board.tex in the config dir)")
+	flag.StringVar(&svgExportPath, "export-svg", "", "file written by Ctrl+Shift+S (default: board.svg in the config dir)")
+	flag.IntVar(&svgCellSize, "svg-cell-size", defaultSVGCellSize, "cell size of the SVG export in SVG units")
+	flag.StringVar(&puzzlePath, "puzzle", "", "play a handmade board from a file (WxH, mine count, then rows of . and *)")
+	flag.StringVar(&logDir, "log-dir", "", "directory for per-game event logs (default: logs in the config dir)")
+	flag.IntVar(&hintLimit, "hints", -1, fmt.Sprintf("hints per game (default: unlimited on Beginner, %d otherwise)", defaultHintLimit))
+	flag.IntVar(&safeZone, "safe-zone", defaultSafePadding, fmt.Sprintf("cells kept clear around the first click: 0 none, 1 a 3x3 block, 2 a 5x5 block (0-%d)", maxSafePadding))
+	flag.IntVar(&cellSizePx, "cell-size", defaultCellSize, fmt.Sprintf("cell size in pixels (%d-%d)", minCellSize, maxCellSize))
+	flag.Parse()
+	cellSizePx = clamp(cellSizePx, minCellSize, maxCellSize)
+	svgCellSize = max(svgCellSize, 1)
+	safeZone = clamp(safeZone, 0, maxSafePadding)
+	touchLongPressDur = time.Duration(max(longPressMs, 1)) * time.Millisecond
+	touchMoveSlopPx = max(touchMoveSlopPx, 0)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "cell-size" {
+			cellSizeExplicit = true
+		}
+	})
+
 	rand.Seed(time.Now().UnixNano())
-	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeDisabled)
+	if *benchmark > 0 {
+		runBenchmark(*benchmark, presets[2])
+		return
+	}
+	var puzzle *board
+	if puzzlePath != "" {
+		var err error
+		if puzzle, err = parsePuzzleFile(puzzlePath); err != nil {
+			fmt.Fprintln(os.Stderr, "puzzle:", err)
+			os.Exit(1)
+		}
+	}
+	if *headless {
+		hg := newHeadlessGame()
+		if puzzle != nil {
+			hg.loadPuzzle(puzzle, puzzleName(puzzlePath))
+		}
+		if err := hg.RunHeadless(os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, "headless:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	// closing the window (Alt+F4, Cmd+Q) goes through Update so the window
+	// state can be read before it is gone
+	ebiten.SetWindowClosingHandled(true)
 	g := newGame()
-	if err := ebiten.RunGame(g); err != nil {
+	if puzzle != nil {
+		g.loadPuzzle(puzzle, puzzleName(puzzlePath))
+	}
+	if !noTelemetry {
+		g.startTelemetry()
+	}
+	restoreWindow()
+
+	defer g.quitOnSignal()()
+
+	err := ebiten.RunGame(g)
+	if serr := g.Shutdown(); serr != nil {
+		fmt.Fprintln(os.Stderr, "saving records:", serr)
+	}
+	if err != nil {
 		panic(err)
 	}
 }
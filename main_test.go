@@ -0,0 +1,1521 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBoard builds a placed board from rows of '.' (safe) and '*' (mine),
+// every cell hidden.
+func testBoard(t *testing.T, rows ...string) *board {
+	t.Helper()
+	b := &board{W: len(rows[0]), H: len(rows), placed: true, safePadding: defaultSafePadding}
+	b.cells = make([][]cell, b.H)
+	for y, row := range rows {
+		if len(row) != b.W {
+			t.Fatalf("row %d has %d cells, want %d", y, len(row), b.W)
+		}
+		b.cells[y] = make([]cell, b.W)
+		for x, ch := range row {
+			if ch == '*' {
+				b.cells[y][x].Mine = true
+				b.Mines++
+			}
+		}
+	}
+	b.computeAdjacent()
+	b.SetSeed(1)
+	return b
+}
+
+// openCell reveals (x,y) alone, with no cascade.
+func openCell(t *testing.T, b *board, x, y int) {
+	t.Helper()
+	if !b.revealOne(x, y) {
+		t.Fatalf("cell (%d,%d) did not open", x, y)
+	}
+}
+
+// flagCell puts a flag on (x,y).
+func flagCell(t *testing.T, b *board, x, y int) {
+	t.Helper()
+	if !b.toggleMark(x, y, false) {
+		t.Fatalf("cell (%d,%d) could not be flagged", x, y)
+	}
+}
+
+func TestFindSafeHintDeterministic(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, b *board)
+		want       point
+		wantReason string
+	}{
+		{
+			name:       "nothing open, every cell ties",
+			setup:      func(*testing.T, *board) {},
+			want:       point{X: 0, Y: 0},
+			wantReason: "Lowest risk",
+		},
+		{
+			name: "satisfied number frees its neighbours",
+			setup: func(t *testing.T, b *board) {
+				openCell(t, b, 1, 1)
+				flagCell(t, b, 0, 0)
+			},
+			want:       point{X: 1, Y: 0},
+			wantReason: "Deduced safe",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t,
+				"*...",
+				"....",
+				"....",
+				"....",
+			)
+			tc.setup(t, b)
+			for i := 0; i < 20; i++ {
+				x, y, reason, ok := b.findSafeHint(true)
+				if !ok || (point{X: x, Y: y}) != tc.want {
+					t.Fatalf("try %d: hint (%d,%d) ok=%v, want %v", i, x, y, ok, tc.want)
+				}
+				if !strings.HasPrefix(reason, tc.wantReason) {
+					t.Fatalf("try %d: reason %q, want %q...", i, reason, tc.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestChordThreshold(t *testing.T) {
+	// the 2 at (1,1) sees both mines; flags go on these cells in order
+	order := [][2]int{{0, 0}, {2, 0}, {0, 1}, {2, 1}}
+	tests := []struct {
+		name      string
+		threshold int
+		flags     int
+		want      bool
+	}{
+		{"-1 one short", -1, 0, false},
+		{"-1 exact", -1, 1, true},
+		{"-1 one over", -1, 2, false},
+		{"0 one short", 0, 1, false},
+		{"0 exact", 0, 2, true},
+		{"0 one over", 0, 3, false},
+		{"+1 one short", 1, 2, false},
+		{"+1 exact", 1, 3, true},
+		{"+1 one over", 1, 4, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t,
+				"*.*",
+				"...",
+				"...",
+			)
+			openCell(t, b, 1, 1)
+			for _, p := range order[:tc.flags] {
+				flagCell(t, b, p[0], p[1])
+			}
+			if got := b.chordThresholdMet(1, 1, tc.threshold); got != tc.want {
+				t.Fatalf("chordThresholdMet = %v, want %v", got, tc.want)
+			}
+			if _, changed := b.chord(1, 1, tc.threshold); changed != tc.want {
+				t.Fatalf("chord changed = %v, want %v", changed, tc.want)
+			}
+		})
+	}
+}
+
+func TestComputeWeightedScore(t *testing.T) {
+	tests := []struct {
+		name        string
+		elapsed     time.Duration
+		wrongFlags  int
+		penaltyMode bool
+		want        float64
+	}{
+		{"no wrong flags", 20 * time.Second, 0, false, 2000.0 / 20},
+		{"one wrong flag", 20 * time.Second, 1, false, 2000.0 / 30},
+		{"zero time counts as a second", 0, 0, false, 2000},
+		{"under a second counts as a second", 500 * time.Millisecond, 0, false, 2000},
+		{"minimum time with a wrong flag", 0, 1, false, 2000.0 / 11},
+		{"penalty mode has it in the time", 20 * time.Second, 1, true, 2000.0 / 20},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			// one opening plus the 2 shut in between the mines: 3BV 2
+			g.b = testBoard(t,
+				"*.*",
+				"...",
+				"...",
+				"...",
+			)
+			if bv := g.b.Compute3BV(); bv != 2 {
+				t.Fatalf("3BV = %d, want 2", bv)
+			}
+			g.elapsed = tc.elapsed
+			g.wrongFlags = tc.wrongFlags
+			g.flagPenaltyMode = tc.penaltyMode
+			if got := g.computeWeightedScore(); math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("score = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTooltipPos(t *testing.T) {
+	const sw, sh, w, h = 300, 200, 80, 20
+	c := cellSizePx
+	tests := []struct {
+		name         string
+		px, py       int
+		w, h         int
+		wantX, wantY int
+	}{
+		{"top left", 0, 0, w, h, 2 * c, c},
+		{"top right", sw - c, 0, w, h, sw - w, c},
+		{"bottom left", 0, sh - c, w, h, 2 * c, sh - h},
+		{"bottom right", sw - c, sh - c, w, h, sw - w, sh - h},
+		{"wider than the screen", sw - c, sh - c, sw + 10, sh + 10, 0, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			x, y := tooltipPos(tc.px, tc.py, tc.w, tc.h, sw, sh)
+			if x != tc.wantX || y != tc.wantY {
+				t.Fatalf("tooltipPos = (%d,%d), want (%d,%d)", x, y, tc.wantX, tc.wantY)
+			}
+			if tc.w <= sw && (x < 0 || y < 0 || x+tc.w > sw || y+tc.h > sh) {
+				t.Fatalf("tooltip at (%d,%d) leaves the screen", x, y)
+			}
+		})
+	}
+}
+
+func TestTooltipLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		practice bool
+		state    gameState
+		x, y     int
+		want     string
+	}{
+		{"hidden cell mid-game", false, statePlaying, 1, 1, "(1,1) adj:?"},
+		{"revealed cell mid-game", false, statePlaying, 2, 2, "(2,2) adj:0"},
+		{"hidden cell in practice mode", true, statePlaying, 1, 1, "(1,1) adj:1"},
+		{"hidden mine in practice mode", true, statePlaying, 0, 0, "(0,0) adj:mine"},
+		{"hidden cell after the game", false, stateLost, 1, 0, "(1,0) adj:1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t,
+				"*..",
+				"...",
+				"...",
+			)
+			openCell(t, g.b, 2, 2)
+			g.practiceMode = tc.practice
+			g.state = tc.state
+			if got := g.tooltipLabel(tc.x, tc.y); got != tc.want {
+				t.Fatalf("tooltipLabel = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDensityByRing(t *testing.T) {
+	tests := []struct {
+		name           string
+		w, h, mines    int
+		clickX, clickY int
+		padding        int
+	}{
+		{"beginner centre", 9, 9, 10, 4, 4, 1},
+		{"beginner corner", 9, 9, 10, 0, 0, 1},
+		{"intermediate edge", 16, 16, 40, 0, 7, 1},
+		{"expert off centre", 30, 16, 99, 20, 3, 1},
+		{"expert without a safe zone", 30, 16, 99, 20, 3, 0},
+		{"beginner with a wide safe zone", 9, 9, 10, 4, 4, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &board{safePadding: tc.padding}
+			b.configure(tc.w, tc.h, tc.mines)
+			b.SetSeed(7)
+			b.placeMines(tc.clickX, tc.clickY)
+			rings := b.DensityByRing(tc.clickX, tc.clickY)
+			// without a safe zone only the clicked cell is kept clear
+			if tc.padding > 0 && rings[0] != 0 {
+				t.Fatalf("ring 0 density = %v, want 0", rings[0])
+			}
+			cells := make([]int, len(rings))
+			for y := 0; y < b.H; y++ {
+				for x := 0; x < b.W; x++ {
+					cells[max(max(absInt(x-tc.clickX), absInt(y-tc.clickY))-1, 0)]++
+				}
+			}
+			total := 0.0
+			for r, d := range rings {
+				total += d * float64(cells[r])
+			}
+			if math.Round(total) != float64(b.Mines) {
+				t.Fatalf("rings hold %v mines, want %d", total, b.Mines)
+			}
+		})
+	}
+}
+
+func TestAutoFlagSingles(t *testing.T) {
+	tests := []struct {
+		name  string
+		rows  []string
+		open  [][2]int // nil opens every safe cell
+		flags [][2]int
+		want  int
+	}{
+		{
+			name: "three isolated 1s",
+			rows: []string{"*...*...*", "........."},
+			want: 3,
+		},
+		{
+			name: "more than one candidate",
+			rows: []string{"*..", "...", "..."},
+			open: [][2]int{{1, 1}},
+			want: 0,
+		},
+		{
+			name:  "mine already flagged",
+			rows:  []string{"*..", "...", "..."},
+			flags: [][2]int{{0, 0}},
+			want:  0,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t, tc.rows...)
+			open := tc.open
+			if open == nil {
+				for y := 0; y < b.H; y++ {
+					for x := 0; x < b.W; x++ {
+						if !b.cells[y][x].Mine {
+							open = append(open, [2]int{x, y})
+						}
+					}
+				}
+			}
+			for _, p := range open {
+				openCell(t, b, p[0], p[1])
+			}
+			for _, p := range tc.flags {
+				flagCell(t, b, p[0], p[1])
+			}
+			if got := len(b.AutoFlagSingles()); got != tc.want {
+				t.Fatalf("AutoFlagSingles = %d, want %d", got, tc.want)
+			}
+			if b.flagsCnt != len(tc.flags)+tc.want {
+				t.Fatalf("flagsCnt = %d, want %d", b.flagsCnt, len(tc.flags)+tc.want)
+			}
+			for y := 0; y < b.H; y++ {
+				for x := 0; x < b.W; x++ {
+					if c := b.cells[y][x]; c.Flagged() && !c.Mine {
+						t.Fatalf("safe cell (%d,%d) was flagged", x, y)
+					}
+				}
+			}
+			if got := len(b.AutoFlagSingles()); got != 0 {
+				t.Fatalf("second AutoFlagSingles = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestResetKeepMines(t *testing.T) {
+	for _, d := range presets {
+		t.Run(d.Name, func(t *testing.T) {
+			b := newBoard(d.W, d.H, d.Mines)
+			b.SetSeed(3)
+			b.placeMines(0, 0)
+			b.reveal(0, 0)
+			flagged := 0
+			for y := 0; y < b.H && flagged < 3; y++ {
+				for x := 0; x < b.W && flagged < 3; x++ {
+					if !b.cells[y][x].Revealed() {
+						flagCell(t, b, x, y)
+						flagged++
+					}
+				}
+			}
+			before := make([][]cell, b.H)
+			for y := range b.cells {
+				before[y] = append([]cell(nil), b.cells[y]...)
+			}
+
+			b.resetKeepMines()
+
+			mines := 0
+			for y := 0; y < b.H; y++ {
+				for x := 0; x < b.W; x++ {
+					c := b.cells[y][x]
+					if c.Mine {
+						mines++
+					}
+					if c.Mine != before[y][x].Mine || c.Adjacent != before[y][x].Adjacent {
+						t.Fatalf("cell (%d,%d) layout changed", x, y)
+					}
+					if c.State != StateHidden {
+						t.Fatalf("cell (%d,%d) state %v, want hidden", x, y, c.State)
+					}
+				}
+			}
+			if mines != b.Mines {
+				t.Fatalf("%d mines, want %d", mines, b.Mines)
+			}
+			if b.revealedCnt != 0 || b.flagsCnt != 0 {
+				t.Fatalf("revealedCnt=%d flagsCnt=%d, want 0", b.revealedCnt, b.flagsCnt)
+			}
+		})
+	}
+}
+
+func TestUpdateCursorBlink(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+	tests := []struct {
+		name  string
+		ticks []time.Duration // since the dialog opened
+		want  bool
+	}{
+		{"just opened", nil, true},
+		{"before the interval", []time.Duration{529 * ms}, true},
+		{"at the interval", []time.Duration{530 * ms}, false},
+		{"two intervals", []time.Duration{530 * ms, 1060 * ms}, true},
+		{"late frame restarts the interval", []time.Duration{600 * ms, 1129 * ms}, false},
+		{"late frame then full interval", []time.Duration{600 * ms, 1130 * ms}, true},
+		{"many frames inside one interval", []time.Duration{100 * ms, 200 * ms, 300 * ms, 529 * ms}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &game{cursorBlink: true, lastBlink: start}
+			for _, d := range tc.ticks {
+				g.updateCursorBlink(start.Add(d))
+			}
+			if g.cursorBlink != tc.want {
+				t.Fatalf("cursorBlink = %v, want %v", g.cursorBlink, tc.want)
+			}
+		})
+	}
+}
+
+// tempConfigDir points configFilePath at a fresh directory for the test.
+func tempConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+	return dir
+}
+
+func TestPrepareNextBoardAfterWin(t *testing.T) {
+	for _, d := range presets {
+		t.Run(d.Name, func(t *testing.T) {
+			tempConfigDir(t)
+			g := newHeadlessGame()
+			g.headless = false
+			// setDifficulty would resize the window
+			g.diff = d
+			g.b = newBoard(d.W, d.H, d.Mines)
+			g.reset(false)
+			g.b.placeMines(0, 0)
+			var last [2]int
+			for y := 0; y < g.b.H; y++ {
+				for x := 0; x < g.b.W; x++ {
+					if !g.b.cells[y][x].Mine {
+						last = [2]int{x, y}
+					}
+				}
+			}
+			for y := 0; y < g.b.H; y++ {
+				for x := 0; x < g.b.W; x++ {
+					if !g.b.cells[y][x].Mine && [2]int{x, y} != last {
+						g.b.revealOne(x, y)
+					}
+				}
+			}
+			g.revealCell(last[0], last[1])
+			if g.state != stateWon {
+				t.Fatalf("state = %v, want won", g.state)
+			}
+
+			var nb *board
+			deadline := time.Now().Add(5 * time.Second)
+			for nb == nil && time.Now().Before(deadline) {
+				g.nextMu.Lock()
+				nb = g.nextBoard
+				g.nextMu.Unlock()
+				time.Sleep(time.Millisecond)
+			}
+			if nb == nil {
+				t.Fatal("nextBoard is still nil after a win")
+			}
+			if nb.W != d.W || nb.H != d.H || nb.Mines != d.Mines {
+				t.Fatalf("next board %dx%d with %d mines, want %dx%d with %d", nb.W, nb.H, nb.Mines, d.W, d.H, d.Mines)
+			}
+			mines := 0
+			for y := range nb.cells {
+				for x := range nb.cells[y] {
+					if nb.cells[y][x].Mine {
+						mines++
+					}
+				}
+			}
+			if !nb.placed || mines != d.Mines {
+				t.Fatalf("next board placed=%v with %d mines laid, want %d", nb.placed, mines, d.Mines)
+			}
+			if g.takeNextBoard() != nb {
+				t.Fatal("takeNextBoard did not hand over the prepared board")
+			}
+		})
+	}
+}
+
+func TestCorroboratedFlags(t *testing.T) {
+	rows := []string{
+		".*..*",
+		".....",
+		".....",
+	}
+	tests := []struct {
+		name  string
+		open  [][2]int
+		flags [][2]int
+		want  [][2]int
+	}{
+		{
+			name:  "one flag vouched for twice",
+			open:  [][2]int{{0, 0}, {2, 0}, {4, 1}},
+			flags: [][2]int{{1, 0}, {4, 0}},
+			want:  [][2]int{{1, 0}},
+		},
+		{
+			name:  "both vouched for twice",
+			open:  [][2]int{{0, 0}, {2, 0}, {3, 1}, {4, 1}},
+			flags: [][2]int{{1, 0}, {4, 0}},
+			want:  [][2]int{{1, 0}, {4, 0}},
+		},
+		{
+			name:  "single number is not enough",
+			open:  [][2]int{{0, 0}, {4, 1}},
+			flags: [][2]int{{1, 0}, {4, 0}},
+		},
+		{
+			name: "no flags",
+			open: [][2]int{{0, 0}, {2, 0}, {3, 1}, {4, 1}},
+		},
+		{
+			name:  "extra flag leaves the numbers unsatisfied",
+			open:  [][2]int{{0, 0}, {2, 0}},
+			flags: [][2]int{{1, 0}, {1, 1}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t, rows...)
+			for _, p := range tc.open {
+				openCell(t, b, p[0], p[1])
+			}
+			for _, p := range tc.flags {
+				flagCell(t, b, p[0], p[1])
+			}
+			got := b.CorroboratedFlags()
+			if len(got) != len(tc.want) {
+				t.Fatalf("CorroboratedFlags = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("CorroboratedFlags = %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCardinal(t *testing.T) {
+	tests := []struct {
+		name     string
+		x, y     int
+		toroidal bool
+		want     int
+	}{
+		{"interior", 4, 4, false, 4},
+		{"top edge", 4, 0, false, 3},
+		{"left edge", 0, 4, false, 3},
+		{"bottom edge", 4, 8, false, 3},
+		{"right edge", 8, 4, false, 3},
+		{"top left corner", 0, 0, false, 2},
+		{"bottom right corner", 8, 8, false, 2},
+		{"corner on a torus", 0, 0, true, 4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBoard(9, 9, 10)
+			b.Toroidal = tc.toroidal
+			seen := map[[2]int]bool{}
+			b.cardinal(tc.x, tc.y, func(nx, ny int) {
+				if absInt(nx-tc.x)+absInt(ny-tc.y) != 1 && !tc.toroidal {
+					t.Fatalf("(%d,%d) is not a cardinal neighbour of (%d,%d)", nx, ny, tc.x, tc.y)
+				}
+				if nx != tc.x && ny != tc.y {
+					t.Fatalf("(%d,%d) is diagonal to (%d,%d)", nx, ny, tc.x, tc.y)
+				}
+				seen[[2]int{nx, ny}] = true
+			})
+			if len(seen) != tc.want {
+				t.Fatalf("cardinal visited %d cells, want %d", len(seen), tc.want)
+			}
+			all := 0
+			b.around(tc.x, tc.y, func(int, int) { all++ })
+			if all <= tc.want {
+				t.Fatalf("around visited %d cells, want more than cardinal's %d", all, tc.want)
+			}
+		})
+	}
+}
+
+func TestCustomSeedLineMatchesLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    int64
+		hasSeed bool
+	}{
+		{"random seed", 0, false},
+		{"seed set in the dialog", 1234567890, true},
+		{"negative seed", -42, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			if tc.hasSeed {
+				// what Enter in the dialog does with a seed typed after Ctrl+S
+				g.b.SetSeed(tc.seed)
+			}
+			var shown int64
+			if _, err := fmt.Sscanf(g.customSeedLine(), "Current seed: %d", &shown); err != nil {
+				t.Fatalf("parsing %q: %v", g.customSeedLine(), err)
+			}
+			if tc.hasSeed && shown != tc.seed {
+				t.Fatalf("dialog shows seed %d, want %d", shown, tc.seed)
+			}
+
+			g.b.placeMines(4, 4)
+			ref := newBoard(g.b.W, g.b.H, g.b.Mines)
+			ref.SetSeed(shown)
+			ref.placeMines(4, 4)
+			for y := range ref.cells {
+				for x := range ref.cells[y] {
+					if g.b.cells[y][x].Mine != ref.cells[y][x].Mine {
+						t.Fatalf("layout differs from seed %d at (%d,%d)", shown, x, y)
+					}
+				}
+			}
+			if g.customSeedLine() != fmt.Sprintf("Current seed: %d  (Ctrl+C: copy)", shown) {
+				t.Fatalf("seed line changed after placing mines: %q", g.customSeedLine())
+			}
+		})
+	}
+}
+
+func TestRiskiestCell(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   []string
+		open   [][2]int
+		flags  [][2]int
+		want   point
+		wantP  float64
+		wantOK bool
+	}{
+		{
+			name:   "nothing open, ties go to the first cell",
+			rows:   []string{"...", "..*", "..."},
+			want:   point{X: 0, Y: 0},
+			wantP:  1.0 / 9,
+			wantOK: true,
+		},
+		{
+			name:   "last hidden cell is certain",
+			rows:   []string{"...", "..*", "..."},
+			open:   [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}, {2, 2}},
+			want:   point{X: 2, Y: 1},
+			wantP:  1,
+			wantOK: true,
+		},
+		{
+			name:   "a 2 beats the global density",
+			rows:   []string{"*.*.", "....", "....", "...."},
+			open:   [][2]int{{1, 0}},
+			want:   point{X: 0, Y: 0},
+			wantP:  2.0 / 5,
+			wantOK: true,
+		},
+		{
+			name:  "flags are not candidates",
+			rows:  []string{"...", "..*", "..."},
+			open:  [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}, {2, 2}},
+			flags: [][2]int{{2, 1}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t, tc.rows...)
+			for _, p := range tc.open {
+				openCell(t, b, p[0], p[1])
+			}
+			for _, p := range tc.flags {
+				flagCell(t, b, p[0], p[1])
+			}
+			x, y, p, ok := b.riskiestCell()
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if (point{X: x, Y: y}) != tc.want || math.Abs(p-tc.wantP) > 1e-9 {
+				t.Fatalf("riskiestCell = (%d,%d) %.3f, want %v %.3f", x, y, p, tc.want, tc.wantP)
+			}
+			probs := b.ComputeProbabilities()
+			for yy := range probs {
+				for xx, q := range probs[yy] {
+					if c := b.cells[yy][xx]; !c.Revealed() && !c.Flagged() && q > p {
+						t.Fatalf("(%d,%d) has %.3f, above the riskiest %.3f", xx, yy, q, p)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(b *board)
+		wantErr string
+	}{
+		{"consistent board", func(*board) {}, ""},
+		{"revealed count off", func(b *board) { b.revealedCnt++ }, "revealedCnt"},
+		{"flag count off", func(b *board) { b.flagsCnt-- }, "flagsCnt"},
+		{"wrong number", func(b *board) { b.cells[2][2].Adjacent = 3 }, "Adjacent"},
+		{"explosion without a mine", func(b *board) { b.cells[1][3].State = StateExploded }, "exploded"},
+		{"extra mine", func(b *board) { b.cells[1][3].Mine = true }, "Adjacent"},
+		{"mine count off", func(b *board) { b.Mines++ }, "mines"},
+		{"short row", func(b *board) { b.cells[1] = b.cells[1][:3] }, "row 1"},
+		{"missing row", func(b *board) { b.cells = b.cells[:3] }, "rows"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t,
+				"*...",
+				"....",
+				"....",
+				"...*",
+			)
+			openCell(t, b, 1, 1)
+			openCell(t, b, 2, 2)
+			flagCell(t, b, 0, 0)
+			tc.corrupt(b)
+			err := b.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Validate = %v, want an error about %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuickFlagRouting(t *testing.T) {
+	tests := []struct {
+		name      string
+		quickFlag bool
+		primary   bool
+		onFace    bool
+		want      CellState
+	}{
+		{"left click reveals", false, true, false, StateRevealed},
+		{"right click flags", false, false, false, StateFlagged},
+		{"quick flag: left click flags", true, true, false, StateFlagged},
+		{"quick flag: right click reveals", true, false, false, StateRevealed},
+		{"smiley restarts", false, true, true, StateHidden},
+		{"quick flag: smiley still restarts", true, true, true, StateHidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t,
+				"*...",
+				"....",
+				"....",
+				"...*",
+			)
+			g.QuickFlagMode = tc.quickFlag
+			g.faceRect = image.Rect(0, 0, 10, 10)
+			// flag (2,2) first, so a restart has something to clear
+			g.b.toggleMark(2, 2, false)
+
+			px, py := g.cellOrigin(1, 1)
+			half := int(g.cellSpan()) / 2
+			mx, my := px+half, py+half
+			if tc.onFace {
+				mx, my = 5, 5
+			}
+			if tc.primary {
+				g.handlePrimaryAt(mx, my)
+			} else {
+				g.handleSecondaryAt(mx, my)
+			}
+
+			if tc.onFace {
+				for y := range g.b.cells {
+					for x := range g.b.cells[y] {
+						if s := g.b.cells[y][x].State; s != StateHidden {
+							t.Fatalf("cell (%d,%d) state %v after restart, want hidden", x, y, s)
+						}
+					}
+				}
+				return
+			}
+			if got := g.b.cells[1][1].State; got != tc.want {
+				t.Fatalf("cell state %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProgressConvergesAfterWin(t *testing.T) {
+	tests := []struct {
+		name  string
+		start float64
+	}{
+		{"from empty", 0},
+		{"from halfway", 0.5},
+		{"from nearly done", 0.95},
+		{"already full", 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t,
+				"*...",
+				"....",
+				"....",
+				"...*",
+			)
+			for y := range g.b.cells {
+				for x := range g.b.cells[y] {
+					if !g.b.cells[y][x].Mine {
+						openCell(t, g.b, x, y)
+					}
+				}
+			}
+			if !g.b.isWin() {
+				t.Fatal("board is not won")
+			}
+			g.displayedProgress = tc.start
+			prev := tc.start
+			for i := 0; i < 30; i++ {
+				g.stepProgress()
+				if g.displayedProgress < prev || g.displayedProgress > 1 {
+					t.Fatalf("frame %d: progress %v after %v", i, g.displayedProgress, prev)
+				}
+				prev = g.displayedProgress
+			}
+			if g.displayedProgress != 1 {
+				t.Fatalf("progress %v after 30 frames, want 1", g.displayedProgress)
+			}
+		})
+	}
+}
+
+func TestBlendColor(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	bg := color.RGBA{192, 192, 192, 255}
+	shadow := color.RGBA{128, 128, 128, 255}
+	tests := []struct {
+		name   string
+		bg, fg color.Color
+		alpha  float64
+		want   color.RGBA
+	}{
+		{"half white over black", black, white, 0.5, color.RGBA{127, 127, 127, 255}},
+		{"no shadow keeps the background", bg, shadow, 0, bg},
+		{"full shadow", bg, shadow, 1, shadow},
+		{"quarter white over black", black, white, 0.25, color.RGBA{63, 63, 63, 255}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := blendColor(tc.bg, tc.fg, tc.alpha); got != tc.want {
+				t.Fatalf("blendColor = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleFocus(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lost, back := start.Add(10*time.Second), start.Add(15*time.Second)
+	tests := []struct {
+		name         string
+		autoPause    bool
+		state        gameState
+		pausedByHand bool
+		wantShift    time.Duration
+		wantPaused   bool
+	}{
+		{"focus away and back", true, statePlaying, false, 5 * time.Second, false},
+		{"paused by hand stays paused", true, statePlaying, true, 0, true},
+		{"setting off", false, statePlaying, false, 0, false},
+		{"game over", true, stateWon, false, 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.AutoPauseOnFocusLoss = tc.autoPause
+			g.state = tc.state
+			g.timerStart = start
+			if tc.pausedByHand {
+				g.setPaused(true, start.Add(time.Second))
+			}
+			wantStart := g.timerStart.Add(tc.wantShift)
+
+			g.handleFocus(false, lost)
+			if tc.autoPause && tc.state == statePlaying && !g.paused {
+				t.Fatal("losing focus did not pause")
+			}
+			g.handleFocus(true, back)
+
+			if g.paused != tc.wantPaused {
+				t.Fatalf("paused = %v, want %v", g.paused, tc.wantPaused)
+			}
+			if g.autopaused {
+				t.Fatal("autopaused still set after focus returned")
+			}
+			if !g.timerStart.Equal(wantStart) {
+				t.Fatalf("timerStart moved by %v, want %v", g.timerStart.Sub(start), tc.wantShift)
+			}
+		})
+	}
+}
+
+func TestSortScoreKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{
+			name: "presets in difficulty order",
+			keys: []string{"Expert_30x16_99", "Beginner_9x9_10", "Intermediate_16x16_40"},
+			want: []string{"Beginner_9x9_10", "Intermediate_16x16_40", "Expert_30x16_99"},
+		},
+		{
+			name: "custom boards after the presets, smallest first",
+			keys: []string{
+				"Custom_40x20_150",
+				"Expert_30x16_99",
+				"Custom_5x5_3",
+				"Beginner_9x9_10",
+				"Custom_10x10_20",
+				"Intermediate_16x16_40",
+			},
+			want: []string{
+				"Beginner_9x9_10",
+				"Intermediate_16x16_40",
+				"Expert_30x16_99",
+				"Custom_5x5_3",
+				"Custom_10x10_20",
+				"Custom_40x20_150",
+			},
+		},
+		{
+			name: "same area falls back to the name",
+			keys: []string{"Custom_20x5_10", "Custom_10x10_30", "Custom_10x10_20"},
+			want: []string{"Custom_10x10_20", "Custom_10x10_30", "Custom_20x5_10"},
+		},
+		{
+			name: "variants stay with their difficulty",
+			keys: []string{"Intermediate_16x16_40", "Beginner_9x9_10_noflags", "Custom_8x8_10", "Beginner_9x9_10"},
+			want: []string{"Beginner_9x9_10", "Beginner_9x9_10_noflags", "Intermediate_16x16_40", "Custom_8x8_10"},
+		},
+		{
+			name: "countdown then daily last",
+			keys: []string{dailyPrefix + "_Beginner_9x9_10", "Custom_50x50_500", countdownPrefix + "_Beginner_9x9_10", "Expert_30x16_99"},
+			want: []string{"Expert_30x16_99", "Custom_50x50_500", countdownPrefix + "_Beginner_9x9_10", dailyPrefix + "_Beginner_9x9_10"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			keys := append([]string(nil), tc.keys...)
+			sortScoreKeys(keys)
+			if strings.Join(keys, " ") != strings.Join(tc.want, " ") {
+				t.Fatalf("sorted %v, want %v", keys, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalisedTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds float64
+		d       difficulty
+		want    float64
+	}{
+		{"beginner is sparser, so slower", 60, presets[0], 75.816},
+		{"intermediate is the standard", 60, presets[1], 59.904},
+		{"expert is denser, so faster", 120, presets[2], 90.7636},
+		{"empty board", 60, difficulty{W: 9, H: 9}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := normalisedTime(tc.seconds, tc.d.W, tc.d.H, tc.d.Mines)
+			if math.Abs(got-tc.want) > 1e-3 {
+				t.Fatalf("normalisedTime(%v, %s) = %v, want %v", tc.seconds, tc.d.Name, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConnectivity4Cascade(t *testing.T) {
+	tests := []struct {
+		name         string
+		rows         []string
+		x, y         int
+		want4, want8 int
+	}{
+		{
+			name:  "corner zero between two mines",
+			rows:  []string{"..*", "...", "*.."},
+			x:     0,
+			y:     0,
+			want4: 3,
+			want8: 4,
+		},
+		{
+			name: "number touching the opening only at a corner",
+			rows: []string{
+				".....",
+				"..*..",
+				".*...",
+				".....",
+				".....",
+			},
+			x:     4,
+			y:     4,
+			want4: 16,
+			want8: 17,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opened := map[int]int{}
+			for _, mode := range []int{Connectivity4, Connectivity8} {
+				b := testBoard(t, tc.rows...)
+				b.ConnectivityMode = mode
+				if hit, _ := b.reveal(tc.x, tc.y); hit {
+					t.Fatalf("(%d,%d) is a mine", tc.x, tc.y)
+				}
+				opened[mode] = b.revealedCnt
+			}
+			if opened[Connectivity4] != tc.want4 || opened[Connectivity8] != tc.want8 {
+				t.Fatalf("opened %d with 4-connectivity and %d with 8, want %d and %d",
+					opened[Connectivity4], opened[Connectivity8], tc.want4, tc.want8)
+			}
+			if opened[Connectivity4] >= opened[Connectivity8] {
+				t.Fatal("4-connected cascade is not smaller than the 8-connected one")
+			}
+		})
+	}
+}
+
+func TestRevealAllMinesWrongFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		flags     [][2]int
+		wantWrong int
+	}{
+		{"no flags", nil, 0},
+		{"flag on a mine is shown as the mine", [][2]int{{0, 0}}, 0},
+		{"flag on a safe cell is wrong", [][2]int{{2, 1}}, 1},
+		{"mixed", [][2]int{{0, 0}, {1, 0}, {0, 1}, {2, 2}}, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t,
+				"*..",
+				"...",
+				"..*",
+			)
+			openCell(t, b, 1, 1)
+			for _, p := range tc.flags {
+				flagCell(t, b, p[0], p[1])
+			}
+			flagged := map[[2]int]bool{}
+			for _, p := range tc.flags {
+				flagged[p] = true
+			}
+
+			if got := b.revealAllMines(); got != tc.wantWrong {
+				t.Fatalf("revealAllMines = %d, want %d", got, tc.wantWrong)
+			}
+			if err := b.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			for y := range b.cells {
+				for x := range b.cells[y] {
+					c := b.cells[y][x]
+					switch p := [2]int{x, y}; {
+					case flagged[p] && c.Mine:
+						if c.State != StateRevealed {
+							t.Fatalf("flagged mine (%d,%d) is %v", x, y, c.State)
+						}
+					case flagged[p]:
+						// drawCell draws the cross over the flag from this state
+						if !c.WrongFlag() || !c.Flagged() {
+							t.Fatalf("wrong flag (%d,%d) is %v", x, y, c.State)
+						}
+					case c.Mine:
+						if !c.Revealed() {
+							t.Fatalf("mine (%d,%d) still hidden", x, y)
+						}
+					default:
+						if c.WrongFlag() {
+							t.Fatalf("unflagged cell (%d,%d) marked as a wrong flag", x, y)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSwatchRects(t *testing.T) {
+	panel := image.Rect(0, 0, 300, 200)
+	tests := []struct {
+		n      int
+		firstX int
+	}{
+		{2, 244},
+		{3, 218},
+		{4, 192},
+		{5, 166},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d themes", tc.n), func(t *testing.T) {
+			rects := swatchRects(panel, tc.n)
+			if len(rects) != tc.n {
+				t.Fatalf("%d rects, want %d", len(rects), tc.n)
+			}
+			for i, r := range rects {
+				x := tc.firstX + i*(swatchW+swatchGap)
+				want := image.Rect(x, 178, x+swatchW, 190)
+				if r != want {
+					t.Fatalf("swatch %d at %v, want %v", i, r, want)
+				}
+			}
+			if last := rects[tc.n-1]; last.Max.X != panel.Max.X-10 {
+				t.Fatalf("last swatch ends at %d, want %d", last.Max.X, panel.Max.X-10)
+			}
+		})
+	}
+}
+
+func TestShutdownOnInterrupt(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores map[string]ScoreEntry
+	}{
+		{"no records yet", map[string]ScoreEntry{}},
+		{"a record", map[string]ScoreEntry{"Beginner_9x9_10": {DurationNs: int64(42 * time.Second), Seed: 7}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := tempConfigDir(t)
+			g := newHeadlessGame()
+			g.bestScores = tc.scores
+			g.statsFor("Beginner_9x9_10").recordLoss()
+
+			stop := g.quitOnSignal()
+			defer stop()
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Signal(os.Interrupt); err != nil {
+				// js and windows cannot signal; the saving is still checked
+				t.Logf("cannot send an interrupt here: %v", err)
+				g.quit.Store(true)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for !g.quit.Load() {
+				if time.Now().After(deadline) {
+					t.Fatal("the interrupt did not stop the game")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			if err := g.Shutdown(); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+			if !strings.HasPrefix(scoreFilePath(), dir) {
+				t.Fatalf("scores written to %s, outside %s", scoreFilePath(), dir)
+			}
+			scores := loadScores()
+			if len(scores) != len(tc.scores) {
+				t.Fatalf("scores file holds %d records, want %d", len(scores), len(tc.scores))
+			}
+			for k, want := range tc.scores {
+				if got := scores[k]; got.DurationNs != want.DurationNs || got.Seed != want.Seed {
+					t.Fatalf("record %s = %+v, want %+v", k, got, want)
+				}
+			}
+			if st := loadStats()["Beginner_9x9_10"]; st == nil || st.GamesPlayed != 1 || st.GamesWon != 0 {
+				t.Fatalf("stats file has %+v, want one lost game", st)
+			}
+		})
+	}
+}
+
+func TestScoreKeyMatchesOldFormat(t *testing.T) {
+	diffs := append(append([]difficulty(nil), presets...), difficulty{Name: "Custom", W: 12, H: 7, Mines: 15})
+	for _, d := range diffs {
+		t.Run(d.Name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.diff = d
+			old := fmt.Sprintf("%s_%dx%d_%d", d.Name, d.W, d.H, d.Mines)
+			if got := g.scoreKey(); got != old {
+				t.Fatalf("scoreKey = %q, want %q", got, old)
+			}
+		})
+	}
+}
+
+func TestScoreKeyModifiers(t *testing.T) {
+	base := "Beginner_9x9_10"
+	tests := []struct {
+		name string
+		set  func(g *game)
+		want string
+	}{
+		{"toroidal", func(g *game) { g.b.Toroidal = true }, base + "_toroidal"},
+		{"no-guess and assisted keep their order", func(g *game) { g.assisted = true; g.b.Solvable = true }, base + "_noguess_assisted"},
+		{"safe zone off", func(g *game) { g.b.safePadding = 0 }, base + "_safe0"},
+		{"countdown", func(g *game) { g.CountdownSec = 60 }, countdownPrefix + "_" + base + "_60s"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			tc.set(g)
+			if got := g.scoreKey(); got != tc.want {
+				t.Fatalf("scoreKey = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMiddleClickChord(t *testing.T) {
+	tests := []struct {
+		name        string
+		open        [][2]int
+		flags       [][2]int
+		at          [2]int
+		wantChanged bool
+		wantState   gameState
+	}{
+		{"hidden cell does nothing", nil, nil, [2]int{1, 1}, false, statePlaying},
+		{"number without its flags does nothing", [][2]int{{1, 1}}, nil, [2]int{1, 1}, false, statePlaying},
+		{"satisfied number opens the rest and wins", [][2]int{{1, 1}}, [][2]int{{0, 0}}, [2]int{1, 1}, true, stateWon},
+		{"wrong flag opens the mine and loses", [][2]int{{1, 1}}, [][2]int{{1, 0}}, [2]int{1, 1}, true, stateLost},
+		{"opened zero does nothing", [][2]int{{2, 2}}, nil, [2]int{2, 2}, false, statePlaying},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t,
+				"*..",
+				"...",
+				"...",
+			)
+			for _, p := range tc.open {
+				openCell(t, g.b, p[0], p[1])
+			}
+			for _, p := range tc.flags {
+				flagCell(t, g.b, p[0], p[1])
+			}
+			px, py := g.cellOrigin(tc.at[0], tc.at[1])
+			half := int(g.cellSpan()) / 2
+			if changed := g.handleChordAt(px+half, py+half); changed != tc.wantChanged {
+				t.Fatalf("handleChordAt = %v, want %v", changed, tc.wantChanged)
+			}
+			if g.state != tc.wantState {
+				t.Fatalf("state = %v, want %v", g.state, tc.wantState)
+			}
+			if g.chordClicks != 1 {
+				t.Fatalf("chordClicks = %d, want 1", g.chordClicks)
+			}
+		})
+	}
+}
+
+func TestEndedQuickFlagMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		x         int
+		quickFlag bool
+		wantState gameState
+	}{
+		{"won in quick flag mode", 1, true, stateWon},
+		{"won in normal mode", 1, false, stateWon},
+		{"lost in quick flag mode", 0, true, stateLost},
+		{"lost in normal mode", 0, false, stateLost},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t, "*.")
+			g.QuickFlagMode = tc.quickFlag
+			g.revealCell(tc.x, 0)
+			if g.state != tc.wantState {
+				t.Fatalf("state = %v, want %v", g.state, tc.wantState)
+			}
+			// switching the mode after the game must not change the banner
+			g.QuickFlagMode = !g.QuickFlagMode
+			if g.endedQuickFlag != tc.quickFlag {
+				t.Fatalf("endedQuickFlag = %v, want %v", g.endedQuickFlag, tc.quickFlag)
+			}
+		})
+	}
+}
+
+func TestPlaceMinesAsyncOwnRNG(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   int64
+		sx, sy int
+	}{
+		{"seed 1 from the corner", 1, 0, 0},
+		{"seed 42 from the middle", 42, 4, 4},
+		{"negative seed", -7, 8, 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			async := newBoard(9, 9, 10)
+			async.SetSeed(tc.seed)
+			nb := <-async.placeMinesAsync(context.Background(), tc.sx, tc.sy)
+			if nb == nil {
+				t.Fatal("placement delivered no board")
+			}
+			async.adoptLayout(nb)
+
+			sync := newBoard(9, 9, 10)
+			sync.SetSeed(tc.seed)
+			sync.placeMines(tc.sx, tc.sy)
+			for y := range sync.cells {
+				for x := range sync.cells[y] {
+					if async.cells[y][x].Mine != sync.cells[y][x].Mine {
+						t.Fatalf("(%d,%d) differs from the synchronous layout", x, y)
+					}
+				}
+			}
+			// the original generator is left where the seed put it
+			if got, want := async.rng.Int63(), rand.New(rand.NewSource(tc.seed)).Int63(); got != want {
+				t.Fatalf("board's rng was drawn from: next value %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestReplayRepeatsMarksAndLocks(t *testing.T) {
+	rows := []string{"*...*...*", "........."}
+	tests := []struct {
+		name  string
+		moves func(g *game)
+	}{
+		{"auto-flag over a question mark", func(g *game) {
+			g.markCell(0, 0)
+			g.markCell(0, 0)
+			g.autoFlagSingles()
+		}},
+		{"lock and unlock", func(g *game) {
+			g.lockCell(4, 0)
+			g.lockCell(8, 0)
+			g.lockCell(8, 0)
+		}},
+		{"auto-flag over a locked cell", func(g *game) {
+			g.lockCell(8, 0)
+			g.autoFlagSingles()
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			play := func(moves func(g *game)) *game {
+				g := newHeadlessGame()
+				g.b = testBoard(t, rows...)
+				for y := 0; y < g.b.H; y++ {
+					for x := 0; x < g.b.W; x++ {
+						if !g.b.cells[y][x].Mine {
+							openCell(t, g.b, x, y)
+						}
+					}
+				}
+				moves(g)
+				return g
+			}
+			rec := play(tc.moves)
+			got := play(func(g *game) {
+				for _, ev := range rec.replayEvents {
+					g.playMove(ev)
+				}
+			})
+			for y := range rec.b.cells {
+				for x, want := range rec.b.cells[y] {
+					if c := got.b.cells[y][x]; c.State != want.State || c.Locked != want.Locked {
+						t.Fatalf("(%d,%d) played back as %v locked=%v, want %v locked=%v", x, y, c.State, c.Locked, want.State, want.Locked)
+					}
+				}
+			}
+			if got.b.flagsCnt != rec.b.flagsCnt {
+				t.Fatalf("flagsCnt = %d, want %d", got.b.flagsCnt, rec.b.flagsCnt)
+			}
+		})
+	}
+}
+
+func TestSolvablePlacementKeepsStartSafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		w, h    int
+		mines   int
+		padding int
+		retries int
+	}{
+		{"dense board, one retry", 5, 5, 12, 1, 1},
+		{"dense board, a few retries", 6, 6, 18, 1, 3},
+		{"wide safe zone", 7, 7, 20, 2, 1},
+		{"no safe zone", 5, 5, 8, 0, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 30; seed++ {
+				b := &board{safePadding: tc.padding, Solvable: true, SolvableRetries: tc.retries}
+				b.configure(tc.w, tc.h, tc.mines)
+				b.SetSeed(seed)
+				sx, sy := tc.w/2, tc.h/2
+				b.placeMines(sx, sy)
+				if tc.padding > 0 && b.cells[sy][sx].Mine {
+					t.Fatalf("seed %d: the first click is a mine", seed)
+				}
+				if b.IsSolvable() {
+					continue
+				}
+				// a layout that needs a guess still keeps the whole safe zone
+				for y := range b.cells {
+					for x := range b.cells[y] {
+						if b.cells[y][x].Mine && b.near(x, y, sx, sy) {
+							t.Fatalf("seed %d: unsolvable layout has a mine at (%d,%d) in the safe zone", seed, x, y)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCascadeStopsAtLocks(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       []string
+		locks      [][2]int
+		wantOpened int
+	}{
+		{"lock at the end of a row", []string{"....."}, [][2]int{{4, 0}}, 4},
+		{"lock cuts the row in two", []string{"......"}, [][2]int{{3, 0}}, 3},
+		{"lock beside a zero in a block", []string{"...", "...", "..."}, [][2]int{{2, 2}}, 8},
+		{"no locks", []string{"....."}, nil, 5},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t, tc.rows...)
+			for _, p := range tc.locks {
+				b.toggleLock(p[0], p[1])
+			}
+			if hit, _ := b.reveal(0, 0); hit {
+				t.Fatal("(0,0) is a mine")
+			}
+			if b.revealedCnt != tc.wantOpened {
+				t.Fatalf("opened %d cells, want %d", b.revealedCnt, tc.wantOpened)
+			}
+			for _, p := range tc.locks {
+				if c := b.cells[p[1]][p[0]]; c.Revealed() || !c.Locked {
+					t.Fatalf("locked cell (%d,%d) revealed=%v locked=%v", p[0], p[1], c.Revealed(), c.Locked)
+				}
+			}
+		})
+	}
+}
+
+func TestToggleSymmetricMineCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    difficulty
+		toggles int
+		want    int
+	}{
+		{"expert rounds down to even", presets[2], 1, 98},
+		{"expert back to odd", presets[2], 2, 99},
+		{"beginner is already even", presets[0], 1, 10},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.diff = tc.diff
+			g.b = newBoard(tc.diff.W, tc.diff.H, tc.diff.Mines)
+			g.reset(false)
+			for i := 0; i < tc.toggles; i++ {
+				g.toggleSymmetric()
+			}
+			if g.b.Mines != tc.want {
+				t.Fatalf("Mines = %d before the first click, want %d", g.b.Mines, tc.want)
+			}
+			nb := <-g.b.placeMinesAsync(context.Background(), 0, 0)
+			if nb == nil {
+				t.Fatal("placement delivered no board")
+			}
+			g.b.adoptLayout(nb)
+			if g.b.Mines != tc.want {
+				t.Fatalf("Mines = %d after the layout, want %d", g.b.Mines, tc.want)
+			}
+			if err := g.b.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// defaultMarathonBoards is how many boards a marathon runs;
+// marathon_boards in config.json changes it.
+const defaultMarathonBoards = 5
+
+// marathonPenalty is what a lost board adds to the marathon total.
+const marathonPenalty = 30 * time.Second
+
+var marathonBoards = defaultMarathonBoards
+
+// marathonResult is one finished board of a marathon.
+type marathonResult struct {
+	Elapsed time.Duration
+	Won     bool
+}
+
+// toggleMarathon starts a marathon on the current difficulty, or ends the
+// one in progress.
+func (g *game) toggleMarathon() {
+	g.marathonMode = !g.marathonMode
+	if g.marathonMode {
+		g.tournament = nil
+	}
+	g.marathonResults = nil
+	g.marathonScore = 0
+	g.showMarathon = false
+	g.reset(false)
+	if g.marathonMode {
+		g.showToast(fmt.Sprintf("Marathon: %d boards, a loss adds %ds", marathonBoards, int(marathonPenalty.Seconds())))
+	} else {
+		g.showToast("Marathon off")
+	}
+}
+
+// marathonFinish adds a finished board to the marathon. Another board
+// follows on the next frame until the marathon is complete; then the
+// summary opens and the best total is kept per score key.
+func (g *game) marathonFinish(won bool) {
+	if !g.marathonMode || g.playback != nil || g.headless {
+		return
+	}
+	g.marathonResults = append(g.marathonResults, marathonResult{Elapsed: g.elapsed, Won: won})
+	if won {
+		g.marathonScore += int(g.elapsed.Milliseconds())
+	} else {
+		g.marathonScore += int(marathonPenalty.Milliseconds())
+	}
+	if len(g.marathonResults) < marathonBoards {
+		g.marathonNext = true
+		return
+	}
+	g.showMarathon = true
+	st := g.statsFor(g.scoreKey())
+	if st.BestMarathonMs == 0 || int64(g.marathonScore) < st.BestMarathonMs {
+		st.BestMarathonMs = int64(g.marathonScore)
+	}
+	saveStats(g.stats)
+}
+
+// handleMarathonSummary closes the summary with Enter or Esc and starts
+// the next marathon.
+func (g *game) handleMarathonSummary() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyEnter) && !inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		return
+	}
+	g.showMarathon = false
+	g.marathonResults = nil
+	g.marathonScore = 0
+	g.reset(false)
+}
+
+// marathonStatus is the marathon's progress for the top panel.
+func (g *game) marathonStatus() string {
+	board := min(len(g.marathonResults)+1, marathonBoards)
+	return fmt.Sprintf("[Marathon %d/%d %s]", board, marathonBoards, formatDuration(time.Duration(g.marathonScore)*time.Millisecond))
+}
+
+func (g *game) marathonLines() []string {
+	lines := make([]string, 0, len(g.marathonResults)+3)
+	for i, r := range g.marathonResults {
+		if r.Won {
+			lines = append(lines, fmt.Sprintf("Board %d: %s", i+1, formatDuration(r.Elapsed)))
+		} else {
+			lines = append(lines, fmt.Sprintf("Board %d: lost (+%ds)", i+1, int(marathonPenalty.Seconds())))
+		}
+	}
+	lines = append(lines, "Total: "+formatDuration(time.Duration(g.marathonScore)*time.Millisecond))
+	if st := g.stats[g.scoreKey()]; st != nil && st.BestMarathonMs > 0 {
+		lines = append(lines, "Best:  "+formatDuration(time.Duration(st.BestMarathonMs)*time.Millisecond))
+	}
+	return append(lines, "(Enter: next marathon)")
+}
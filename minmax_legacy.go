@@ -0,0 +1,20 @@
+//go:build !go1.21
+
+package main
+
+// Go 1.21 made min and max builtins; older toolchains get these int-only
+// stand-ins so the package builds either way.
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// particleColors are the sparks of an explosion, whatever the theme.
+var particleColors = []color.Color{
+	rgb(255, 220, 80),
+	rgb(255, 150, 40),
+	rgb(230, 60, 30),
+	rgb(60, 60, 60),
+}
+
+// Particle is one spark of an explosion. X and Y are in board pixels, so
+// sparks stay with the board when it scrolls. Life runs from 1 down to 0,
+// Decay a tick.
+type Particle struct {
+	X, Y   float32
+	VX, VY float32
+	Color  color.Color
+	Life   float32
+	Decay  float32
+}
+
+// subscribeEffects sets off the explosion on a mine hit and the confetti on
+// a win.
+func (g *game) subscribeEffects() {
+	g.events.Subscribe(EventMineHit, func(Event) { g.explode() })
+	g.events.Subscribe(EventGameWon, func(Event) { g.startConfetti() })
+}
+
+// explode throws a fresh burst of 12 to 16 sparks out of the exploded mine
+// and starts the board shaking. Sparks of an earlier burst are dropped.
+func (g *game) explode() {
+	g.particles = g.particles[:0]
+	if !g.animationsEnabled {
+		return
+	}
+	g.shakeFrames = shakeDuration
+	p, ok := g.b.explodedCell()
+	if !ok {
+		return
+	}
+	cx := float32(p.X*cellSizePx + cellSizePx/2)
+	cy := float32(p.Y*cellSizePx + cellSizePx/2)
+	scale := float32(cellSizePx) / defaultCellSize
+	for n := 12 + rand.Intn(5); n > 0; n-- {
+		angle := rand.Float64() * 2 * math.Pi
+		speed := (1.5 + rand.Float32()*2.5) * scale
+		g.particles = append(g.particles, Particle{
+			X:     cx,
+			Y:     cy,
+			VX:    float32(math.Cos(angle)) * speed,
+			VY:    float32(math.Sin(angle)) * speed,
+			Color: particleColors[rand.Intn(len(particleColors))],
+			Life:  1,
+			Decay: 0.02 + rand.Float32()*0.02,
+		})
+	}
+}
+
+// stepParticles moves the sparks one tick, slowing them down, and drops
+// the burnt out ones.
+func (g *game) stepParticles() {
+	live := g.particles[:0]
+	for _, p := range g.particles {
+		p.X += p.VX
+		p.Y += p.VY
+		p.VX *= 0.94
+		p.VY = p.VY*0.94 + 0.08
+		p.Life -= p.Decay
+		if p.Life > 0 {
+			live = append(live, p)
+		}
+	}
+	g.particles = live
+}
+
+// drawParticles draws the sparks on the board image, shrinking and fading
+// as they burn out.
+func (g *game) drawParticles(screen *ebiten.Image) {
+	ox, oy := g.cellOrigin(0, 0)
+	r := float32(cellSizePx) / 8
+	for _, p := range g.particles {
+		pr := r * p.Life
+		if pr < 1 {
+			pr = 1
+		}
+		vector.DrawFilledCircle(screen, float32(ox)+p.X, float32(oy)+p.Y, pr, withAlpha(p.Color, uint8(p.Life*255)), true)
+	}
+}
+
+// explodedCell is where the mine that ended the game went off.
+func (b *board) explodedCell() (point, bool) {
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if b.cells[y][x].Exploded() {
+				return point{X: x, Y: y}, true
+			}
+		}
+	}
+	return point{}, false
+}
+
+// confettiCount is how many pieces of confetti are in the air while a win
+// is being celebrated, and confettiTime how long new ones keep coming.
+const (
+	confettiCount = 60
+	confettiTime  = 3 * time.Second
+)
+
+var confettiColors = []color.Color{
+	rgb(240, 70, 80),
+	rgb(255, 200, 40),
+	rgb(70, 190, 90),
+	rgb(60, 140, 240),
+	rgb(180, 90, 220),
+	rgb(255, 130, 200),
+}
+
+// ConfettiParticle is one piece of win confetti, in screen pixels.
+type ConfettiParticle struct {
+	X, Y   float32
+	VX, VY float32
+	Size   float32
+	Color  color.Color
+}
+
+// startConfetti celebrates a win, once per game.
+func (g *game) startConfetti() {
+	if !g.animationsEnabled || g.confettiDone {
+		return
+	}
+	g.confettiDone = true
+	g.confettiUntil = time.Now().Add(confettiTime)
+	g.confetti = g.confetti[:0]
+	w, _ := g.Layout(0, 0)
+	for i := 0; i < confettiCount; i++ {
+		g.confetti = append(g.confetti, newConfetti(w))
+	}
+}
+
+func newConfetti(screenW int) ConfettiParticle {
+	return ConfettiParticle{
+		X:     rand.Float32() * float32(screenW),
+		VX:    rand.Float32()*1.2 - 0.6,
+		VY:    1 + rand.Float32()*2,
+		Size:  3 + float32(rand.Intn(5)),
+		Color: confettiColors[rand.Intn(len(confettiColors))],
+	}
+}
+
+// stepConfetti lets the confetti fall one tick. Pieces below the screen go;
+// for confettiTime after the win new ones take their place at the top.
+func (g *game) stepConfetti() {
+	if len(g.confetti) == 0 {
+		return
+	}
+	w, h := g.Layout(0, 0)
+	live := g.confetti[:0]
+	for _, c := range g.confetti {
+		c.X += c.VX
+		c.Y += c.VY
+		if c.Y < float32(h) {
+			live = append(live, c)
+		}
+	}
+	g.confetti = live
+	if time.Now().Before(g.confettiUntil) {
+		for len(g.confetti) < confettiCount {
+			g.confetti = append(g.confetti, newConfetti(w))
+		}
+	}
+}
+
+func (g *game) drawConfetti(screen *ebiten.Image) {
+	for _, c := range g.confetti {
+		vector.DrawFilledRect(screen, c.X, c.Y, c.Size, c.Size, c.Color, false)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// flagPenalty is what each wrong flag adds to a win in penalty mode.
+const flagPenalty = 5 * time.Second
+
+// toggleFlagPenalty switches wrong-flag penalty mode and starts a new game
+// under it.
+func (g *game) toggleFlagPenalty() {
+	g.flagPenaltyMode = !g.flagPenaltyMode
+	g.reset(false)
+	if g.flagPenaltyMode {
+		g.showToast(fmt.Sprintf("Wrong-flag penalty: +%ds per wrong flag", int(flagPenalty.Seconds())))
+	} else {
+		g.showToast("Wrong-flag penalty off")
+	}
+}
+
+// applyFlagPenalty adds flagPenalty to the winning time for every flag put
+// on a safe cell during the game. A win leaves no wrong flag on the board,
+// since every safe cell is open, so it counts the flags as they were
+// placed.
+func (g *game) applyFlagPenalty() {
+	if !g.flagPenaltyMode {
+		return
+	}
+	g.flagPenaltyTime = time.Duration(g.wrongFlags) * flagPenalty
+	g.elapsed += g.flagPenaltyTime
+}
+
+// penaltyLine breaks the penalised time down for the win banner.
+func (g *game) penaltyLine() string {
+	return fmt.Sprintf("%s + %d wrong flags x %ds = %s", formatDuration(g.elapsed-g.flagPenaltyTime), g.wrongFlags,
+		int(flagPenalty.Seconds()), formatDuration(g.elapsed))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// pinchStepPx is how far the pinch must move the cell size before it is
+// resized, which keeps the window from resizing on every jitter.
+const pinchStepPx = 2
+
+// handlePinch zooms the board while exactly two fingers are down: the cell
+// size follows the distance between them, relative to where the pinch
+// started. Lifting either finger ends the pinch, and neither touch then
+// counts as a tap.
+func (g *game) handlePinch() {
+	ids := ebiten.TouchIDs()
+	if len(ids) != 2 {
+		g.pinchStartDist = 0
+		return
+	}
+	var pos [2][2]int
+	for i, id := range ids {
+		x, y := ebiten.TouchPosition(id)
+		pos[i] = [2]int{x, y}
+		st, ok := g.touchStarts[id]
+		if !ok {
+			st = touchStart{X: x, Y: y, LastX: x, LastY: y}
+		}
+		st.pinched = true
+		g.touchStarts[id] = st
+	}
+	d := math.Hypot(float64(pos[0][0]-pos[1][0]), float64(pos[0][1]-pos[1][1]))
+	if g.pinchStartDist == 0 {
+		g.pinchStartDist = math.Max(d, 1)
+		g.pinchStartCell = cellSizePx
+		return
+	}
+	target := int(math.Round(float64(g.pinchStartCell)*d/g.pinchStartDist/2)) * 2
+	if absInt(target-cellSizePx) >= pinchStepPx {
+		g.setCellSize(target)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// togglePractice switches practice mode and starts a new game under it, so
+// a game is practice from its first move or not at all.
+func (g *game) togglePractice() {
+	g.practiceMode = !g.practiceMode
+	g.reset(false)
+	if g.practiceMode {
+		g.showToast("Practice mode: unlimited undo, no records")
+	} else {
+		g.showToast("Practice mode off")
+	}
+}
+
+// holdOutcome keeps a practice game open when it is won or lost, so the
+// last move can still be taken back. It reports whether the outcome was
+// held; an outcome the player has accepted goes through.
+func (g *game) holdOutcome(s gameState) bool {
+	if !g.practiceMode || g.playback != nil || g.practicePending == s {
+		return false
+	}
+	g.practicePending = s
+	return true
+}
+
+// handlePracticePending waits on a held outcome: Enter accepts it. Undo is
+// left to the global keys and clears the hold.
+func (g *game) handlePracticePending() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		return
+	}
+	switch g.practicePending {
+	case stateWon:
+		g.onGameWon()
+	case stateLost:
+		g.onGameLost()
+	}
+	g.practicePending = statePlaying
+}
+
+func (g *game) drawPracticePending(screen *ebiten.Image, th theme) {
+	title := "BOOM!"
+	if g.practicePending == stateWon {
+		title = "CLEARED!"
+	}
+	drawBanner(screen, title, []string{"U / Ctrl+Z: undo", "Enter: end the game"}, th)
+}
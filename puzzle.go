@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// puzzlePath is set by --puzzle.
+var puzzlePath string
+
+// parsePuzzleFile reads a handmade board: a "WxH" line, a line with the
+// mine count, then H rows of W cells, '.' for a safe cell and '*' for a
+// mine. The board comes back with its mines placed.
+func parsePuzzleFile(path string) (*board, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), " \t\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) < 2 {
+		return nil, errors.New("want a WxH line and a mine count line")
+	}
+	ws, hs, ok := strings.Cut(strings.ToLower(strings.TrimSpace(lines[0])), "x")
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(hs)
+	if !ok || werr != nil || herr != nil {
+		return nil, fmt.Errorf("size %q is not WxH", lines[0])
+	}
+	if w < 1 || h < 1 || w > maxBoardW || h > maxBoardH {
+		return nil, fmt.Errorf("unsupported board size %dx%d", w, h)
+	}
+	mines, err := strconv.Atoi(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return nil, fmt.Errorf("mine count %q: %v", lines[1], err)
+	}
+	rows := lines[2:]
+	for len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+	if len(rows) != h {
+		return nil, fmt.Errorf("grid has %d rows, want %d", len(rows), h)
+	}
+
+	b := &board{W: w, H: h, Mines: mines, placed: true, start: point{X: -1, Y: -1}}
+	b.cells = make([][]cell, h)
+	count := 0
+	for y, row := range rows {
+		if len(row) != w {
+			return nil, fmt.Errorf("row %d has %d cells, want %d", y+1, len(row), w)
+		}
+		b.cells[y] = make([]cell, w)
+		for x := 0; x < w; x++ {
+			switch row[x] {
+			case '.':
+				if b.start.X < 0 {
+					b.start = point{X: x, Y: y}
+				}
+			case '*':
+				b.cells[y][x].Mine = true
+				count++
+			default:
+				return nil, fmt.Errorf("unexpected %q at (%d,%d)", row[x], x, y)
+			}
+		}
+	}
+	if count != mines {
+		return nil, fmt.Errorf("grid has %d mines, want %d", count, mines)
+	}
+	if b.start.X < 0 {
+		return nil, errors.New("grid has no safe cell")
+	}
+	b.computeAdjacent()
+	b.SetSeed(0)
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// loadPuzzle replaces the game with a board from parsePuzzleFile. Starting
+// over keeps the puzzle; picking a difficulty leaves it.
+func (g *game) loadPuzzle(b *board, name string) {
+	g.diff = difficulty{Name: "Puzzle", W: b.W, H: b.H, Mines: b.Mines, Puzzle: name}
+	g.b = b
+	g.reset(false)
+	if !g.headless {
+		g.resizeWindow()
+	}
+}
+
+// puzzleName is how a puzzle file is labelled in the top panel.
+func puzzleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// ReplayAction is the kind of move a ReplayEvent repeats.
+type ReplayAction int
+
+const (
+	ReplayReveal ReplayAction = iota
+	ReplayFlag
+	ReplayChord
+	ReplayUndo
+	// ReplaySetFlag flags a cell whatever mark it has, as the auto-flaggers
+	// do; ReplayFlag cycles the mark like a right click.
+	ReplaySetFlag
+	ReplayLock
+)
+
+// ReplayEvent is one move, timed from the start of the game clock.
+type ReplayEvent struct {
+	Time   time.Duration `json:"t"`
+	Action ReplayAction  `json:"a"`
+	X      int           `json:"x"`
+	Y      int           `json:"y"`
+}
+
+// Replay is enough to play a game again move by move: the seed and first
+// click rebuild the layout, the events redo the moves.
+type Replay struct {
+	Seed       int64         `json:"seed"`
+	Difficulty difficulty    `json:"difficulty"`
+	Start      point         `json:"start"`
+	Events     []ReplayEvent `json:"events"`
+	// settings that change what a move does
+	ChordThreshold int  `json:"chord_threshold"`
+	AllowQuestion  bool `json:"allow_question"`
+	Solvable       bool `json:"solvable,omitempty"`
+	Toroidal       bool `json:"toroidal,omitempty"`
+	CountdownSec   int  `json:"countdown_sec,omitempty"`
+	SafeZone       int  `json:"safe_zone"`
+	Symmetric      bool `json:"symmetric,omitempty"`
+	Clustered      bool `json:"clustered,omitempty"`
+
+	Won      bool      `json:"won"`
+	Recorded time.Time `json:"recorded"`
+}
+
+// maxReplayList is how many recent replays the REPLAYS overlay offers.
+const maxReplayList = 10
+
+func replayDir() string {
+	return configFilePath("replays")
+}
+
+// subscribeReplay records every move published on the game's bus.
+func (g *game) subscribeReplay() {
+	g.events.Subscribe(EventMove, func(e Event) {
+		m := e.(MoveEvent)
+		g.recordMove(m.Action, m.X, m.Y)
+	})
+}
+
+// recordMove appends a move to the replay of the current game. Moves made
+// by a playback are not recorded again.
+func (g *game) recordMove(action ReplayAction, x, y int) {
+	if g.playback != nil {
+		return
+	}
+	var t time.Duration
+	if !g.timerStart.IsZero() {
+		t = time.Since(g.timerStart)
+	}
+	g.replayEvents = append(g.replayEvents, ReplayEvent{Time: t, Action: action, X: x, Y: y})
+}
+
+// saveReplay writes the finished game to the replays directory.
+func (g *game) saveReplay(won bool) error {
+	// a puzzle's layout does not come from a seed, so it cannot be replayed
+	if g.playback != nil || len(g.replayEvents) == 0 || !g.b.placed || g.diff.Puzzle != "" {
+		return nil
+	}
+	r := Replay{
+		Seed:           g.b.seed,
+		Difficulty:     g.diff,
+		Start:          g.b.start,
+		Events:         g.replayEvents,
+		ChordThreshold: g.ChordThreshold,
+		AllowQuestion:  g.allowQuestion,
+		Solvable:       g.b.Solvable,
+		Toroidal:       g.b.Toroidal,
+		CountdownSec:   g.CountdownSec,
+		SafeZone:       g.b.safePadding,
+		Symmetric:      g.b.SymmetricMines,
+		Clustered:      g.b.ClusteredMines,
+		Won:            won,
+		Recorded:       time.Now(),
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	dir := replayDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := r.Recorded.Format("20060102-150405") + "_" + g.scoreKey() + ".json"
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
+
+// loadReplays returns the most recent replays, newest first. Files that
+// cannot be read are skipped.
+func loadReplays() []*Replay {
+	entries, err := os.ReadDir(replayDir())
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	// names start with a sortable timestamp
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	var out []*Replay
+	for _, name := range names {
+		if len(out) == maxReplayList {
+			break
+		}
+		data, err := os.ReadFile(filepath.Join(replayDir(), name))
+		if err != nil {
+			continue
+		}
+		// replays from before the safe zone setting used the default one
+		r := Replay{SafeZone: defaultSafePadding}
+		if err := json.Unmarshal(data, &r); err != nil {
+			continue
+		}
+		out = append(out, &r)
+	}
+	return out
+}
+
+func (r *Replay) label() string {
+	name := r.Difficulty.Name
+	if r.Difficulty.Daily != "" {
+		name = "Daily"
+	}
+	result := "lost"
+	if r.Won {
+		result = "won "
+	}
+	var length time.Duration
+	if n := len(r.Events); n > 0 {
+		length = r.Events[n-1].Time
+	}
+	return fmt.Sprintf("%s  %-12s %s %s", r.Recorded.Format("2006-01-02 15:04"), name, result, formatDuration(length))
+}
+
+// toggleReplays opens or closes the REPLAYS overlay.
+func (g *game) toggleReplays() {
+	g.showReplays = !g.showReplays
+	if g.showReplays {
+		g.replayList = loadReplays()
+		g.replayIdx = 0
+		g.showHelp = false
+		g.showScores = false
+	}
+}
+
+// handleReplayList picks a replay: Up/Down select, Enter plays, Esc or
+// Shift+R closes.
+func (g *game) handleReplayList() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || shiftKeyJustPressed(ebiten.KeyR) {
+		g.showReplays = false
+		return
+	}
+	if len(g.replayList) == 0 {
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+		g.replayIdx = min(g.replayIdx+1, len(g.replayList)-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+		g.replayIdx = max(g.replayIdx-1, 0)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		g.startPlayback(g.replayList[g.replayIdx])
+	}
+}
+
+func (g *game) replayLines() []string {
+	if len(g.replayList) == 0 {
+		return []string{"No replays yet. Finish a game to record one.", "(Esc to close)"}
+	}
+	lines := make([]string, 0, len(g.replayList)+1)
+	for i, r := range g.replayList {
+		prefix := "  "
+		if i == g.replayIdx {
+			prefix = "> "
+		}
+		lines = append(lines, prefix+r.label())
+	}
+	return append(lines, "Up/Down: select  Enter: play  Esc: close")
+}
+
+// startPlayback rebuilds the replay's layout and hands the board to it.
+func (g *game) startPlayback(r *Replay) {
+	g.showReplays = false
+	chord, question, countdown := g.ChordThreshold, g.allowQuestion, g.CountdownSec
+	g.CountdownSec = r.CountdownSec
+	g.setDifficulty(r.Difficulty)
+	g.b.SetSeed(r.Seed)
+	g.b.Solvable = r.Solvable
+	g.b.Toroidal = r.Toroidal
+	g.b.safePadding = r.SafeZone
+	g.b.SymmetricMines = r.Symmetric
+	g.b.ClusteredMines = r.Clustered
+	// placeMines rounds the count down again for a symmetric replay
+	g.b.Mines = clamp(r.Difficulty.Mines, 1, g.b.maxMines())
+	g.b.placeMines(r.Start.X, r.Start.Y)
+	g.ChordThreshold, g.allowQuestion = r.ChordThreshold, r.AllowQuestion
+	g.savedChord, g.savedQuestion, g.savedCountdown = chord, question, countdown
+	g.playback = r
+	g.playIdx = 0
+	g.playClock = 0
+	g.playPaused = false
+	g.playSpeed = 1
+}
+
+// stopPlayback ends a playback and gives the player a fresh game.
+func (g *game) stopPlayback() {
+	g.playback = nil
+	g.ChordThreshold, g.allowQuestion, g.CountdownSec = g.savedChord, g.savedQuestion, g.savedCountdown
+	g.reset(false)
+}
+
+// updatePlayback replaces player input while a replay runs. Space pauses,
+// F toggles 2x speed and Esc stops.
+func (g *game) updatePlayback() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.stopPlayback()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		g.playPaused = !g.playPaused
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		g.playSpeed = 3 - g.playSpeed
+	}
+	if g.playPaused || g.state != statePlaying {
+		return
+	}
+	g.playClock += time.Second / time.Duration(ebiten.TPS()) * time.Duration(g.playSpeed)
+	g.elapsed = g.playClock
+	g.checkCountdown()
+
+	events := g.playback.Events
+	// a cascade still dripping must finish before the next move, as it did
+	// while recording
+	for g.playIdx < len(events) && events[g.playIdx].Time <= g.playClock && len(g.revealQueue) == 0 {
+		ev := events[g.playIdx]
+		g.playIdx++
+		g.playMove(ev)
+	}
+}
+
+// playMove repeats one recorded move.
+func (g *game) playMove(ev ReplayEvent) {
+	if !g.b.in(ev.X, ev.Y) && ev.Action != ReplayUndo {
+		return
+	}
+	switch ev.Action {
+	case ReplayReveal:
+		g.revealCell(ev.X, ev.Y)
+	case ReplayFlag:
+		g.markCell(ev.X, ev.Y)
+	case ReplayChord:
+		g.chordCell(ev.X, ev.Y)
+	case ReplayUndo:
+		g.undoMove()
+	case ReplaySetFlag:
+		g.setFlag(ev.X, ev.Y)
+	case ReplayLock:
+		g.lockCell(ev.X, ev.Y)
+	}
+}
+
+// playbackStatus is the bottom panel text shown during playback.
+func (g *game) playbackStatus() []string {
+	status := "playing"
+	switch {
+	case g.state != statePlaying:
+		status = "finished"
+	case g.playPaused:
+		status = "paused"
+	}
+	return []string{
+		fmt.Sprintf("REPLAY %s  x%d  move %d/%d", status, g.playSpeed, g.playIdx, len(g.playback.Events)),
+		"Space: pause  F: 2x speed  Esc: stop",
+	}
+}
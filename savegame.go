@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// boardJSON is the on-disk form of a board; board keeps its fields
+// unexported, so it goes through this mirror.
+type boardJSON struct {
+	W                int      `json:"w"`
+	H                int      `json:"h"`
+	Mines            int      `json:"mines"`
+	ConnectivityMode int      `json:"connectivity,omitempty"`
+	Toroidal         bool     `json:"toroidal,omitempty"`
+	SafePadding      int      `json:"safe_padding"`
+	Symmetric        bool     `json:"symmetric,omitempty"`
+	Clustered        bool     `json:"clustered,omitempty"`
+	Cells            [][]cell `json:"cells"`
+	Placed           bool     `json:"placed"`
+	Start            point    `json:"start"`
+	RevealedCnt      int      `json:"revealed"`
+	FlagsCnt         int      `json:"flags"`
+	Seed             int64    `json:"seed"`
+}
+
+func (b *board) MarshalJSON() ([]byte, error) {
+	return json.Marshal(boardJSON{
+		W:                b.W,
+		H:                b.H,
+		Mines:            b.Mines,
+		ConnectivityMode: b.ConnectivityMode,
+		Toroidal:         b.Toroidal,
+		SafePadding:      b.safePadding,
+		Symmetric:        b.SymmetricMines,
+		Clustered:        b.ClusteredMines,
+		Cells:            b.cells,
+		Placed:           b.placed,
+		Start:            b.start,
+		RevealedCnt:      b.revealedCnt,
+		FlagsCnt:         b.flagsCnt,
+		Seed:             b.seed,
+	})
+}
+
+// UnmarshalJSON restores a board and rejects one whose cells do not match
+// its declared size or whose counters disagree with its cells.
+func (b *board) UnmarshalJSON(data []byte) error {
+	// saves from before the safe zone setting used the default one
+	v := boardJSON{SafePadding: defaultSafePadding}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.W < 1 || v.H < 1 || v.W > maxBoardW || v.H > maxBoardH {
+		return fmt.Errorf("unsupported board size %dx%d", v.W, v.H)
+	}
+	if len(v.Cells) != v.H {
+		return fmt.Errorf("board has %d rows, want %d", len(v.Cells), v.H)
+	}
+	for y, row := range v.Cells {
+		if len(row) != v.W {
+			return fmt.Errorf("row %d has %d cells, want %d", y, len(row), v.W)
+		}
+	}
+	nb := board{
+		W:                v.W,
+		H:                v.H,
+		Mines:            v.Mines,
+		ConnectivityMode: v.ConnectivityMode,
+		Toroidal:         v.Toroidal,
+		safePadding:      clamp(v.SafePadding, 0, maxSafePadding),
+		SymmetricMines:   v.Symmetric,
+		ClusteredMines:   v.Clustered,
+		cells:            v.Cells,
+		placed:           v.Placed,
+		start:            v.Start,
+		revealedCnt:      v.RevealedCnt,
+		flagsCnt:         v.FlagsCnt,
+	}
+	nb.SetSeed(v.Seed)
+	if err := nb.Validate(); err != nil {
+		return err
+	}
+	*b = nb
+	return nil
+}
+
+// saveGame is what Ctrl+S writes: the board plus the game around it.
+type saveGame struct {
+	Board      *board     `json:"board"`
+	Difficulty difficulty `json:"difficulty"`
+	State      gameState  `json:"state"`
+	ElapsedNs  int64      `json:"elapsed_ns"`
+	WrongFlags int        `json:"wrong_flags"`
+	Hinted     bool       `json:"hinted"`
+}
+
+func saveGameFilePath() string {
+	return configFilePath("savegame.json")
+}
+
+// Save writes the current game to savegame.json.
+func (g *game) Save() error {
+	if len(g.revealQueue) > 0 {
+		return errors.New("wait for the cascade to finish")
+	}
+	data, err := json.MarshalIndent(saveGame{
+		Board:      g.b,
+		Difficulty: g.diff,
+		State:      g.state,
+		ElapsedNs:  int64(g.elapsed),
+		WrongFlags: g.wrongFlags,
+		Hinted:     g.hintUsed || g.layoutHinted,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(saveGameFilePath(), data, 0o644)
+}
+
+// Load replaces the current game with savegame.json. The current game is
+// left alone if the file is missing or invalid.
+func (g *game) Load() error {
+	data, err := os.ReadFile(saveGameFilePath())
+	if err != nil {
+		return err
+	}
+	var sg saveGame
+	if err := json.Unmarshal(data, &sg); err != nil {
+		return err
+	}
+	if sg.Board == nil {
+		return errors.New("no board in save file")
+	}
+	if sg.Difficulty.W != sg.Board.W || sg.Difficulty.H != sg.Board.H {
+		return fmt.Errorf("board is %dx%d but difficulty says %dx%d", sg.Board.W, sg.Board.H, sg.Difficulty.W, sg.Difficulty.H)
+	}
+
+	g.reset(false)
+	g.nextMu.Lock()
+	g.nextBoard = nil
+	g.nextMu.Unlock()
+
+	g.diff = sg.Difficulty
+	g.b = sg.Board
+	g.state = sg.State
+	g.elapsed = time.Duration(sg.ElapsedNs)
+	g.wrongFlags = sg.WrongFlags
+	g.layoutHinted = sg.Hinted
+	if g.b.placed && g.state == statePlaying {
+		g.timerStart = time.Now().Add(-g.elapsed)
+	}
+	if g.b.placed {
+		g.bv3 = g.b.Compute3BV()
+	}
+	g.resizeWindow()
+	return nil
+}
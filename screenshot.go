@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// renderImage draws one frame into an offscreen image sized by Layout, so
+// it works the same whatever the live window looks like.
+func (g *game) renderImage() *image.RGBA {
+	w, h := g.Layout(0, 0)
+	off := ebiten.NewImage(w, h)
+	defer off.Deallocate()
+	g.drawGame(off)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	off.ReadPixels(img.Pix)
+	return img
+}
+
+// screenshot saves the panel, board and any open overlay as a PNG in the
+// screenshots directory, named after the difficulty and elapsed time.
+func (g *game) screenshot() error {
+	dir := configFilePath("screenshots")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := strings.ToLower(strings.ReplaceAll(g.diff.Name, " ", ""))
+	if g.diff.Daily != "" {
+		name = dailyPrefix + "-" + g.diff.Daily
+	}
+	file := fmt.Sprintf("%s_%s_%.2fs.png", time.Now().Format("20060102-150405"), name, g.elapsed.Seconds())
+	f, err := os.Create(filepath.Join(dir, file))
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, g.renderImage()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
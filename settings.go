@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+)
+
+// setting is one row of the settings panel. adjust moves it one step in
+// dir (1 or -1); a toggle ignores the direction.
+type setting struct {
+	label  string
+	value  func() string
+	adjust func(dir int)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// settings lists what the settings panel offers, most of it also on its
+// own key.
+func (g *game) settings() []setting {
+	return []setting{
+		{"Question marks (Q)", func() string { return onOff(g.allowQuestion) }, func(int) { g.allowQuestion = !g.allowQuestion }},
+		{"No-guess mode (G)", func() string { return onOff(g.NoGuess) }, func(int) { g.toggleNoGuess() }},
+		{"Practice mode (Ctrl+R)", func() string { return onOff(g.practiceMode) }, func(int) { g.togglePractice() }},
+		{"No flags (Ctrl+N)", func() string { return onOff(g.noFlags) }, func(int) { g.toggleNoFlags() }},
+		{"Wrong-flag penalty (Shift+P)", func() string { return onOff(g.flagPenaltyMode) }, func(int) { g.toggleFlagPenalty() }},
+		{"Right-click menu", func() string { return onOff(g.useContextMenu) }, func(int) { g.useContextMenu = !g.useContextMenu }},
+		{"Left-handed mouse (L)", func() string { return onOff(g.leftHanded) }, func(int) { g.toggleLeftHanded() }},
+		{"Colorblind theme (T)", func() string { return onOff(themes[g.themeIdx].Accessible) }, func(int) { g.toggleColorblind() }},
+		{"Countdown (Ctrl+T)", func() string {
+			if g.CountdownSec == 0 {
+				return "off"
+			}
+			return fmt.Sprintf("%ds", g.CountdownSec)
+		}, g.stepCountdown},
+		{"Cell size (+/-)", func() string { return fmt.Sprintf("%dpx", cellSizePx) }, func(dir int) {
+			next := cellSizePx + 2*dir
+			if next > maxCellSize {
+				next = minCellSize
+			}
+			g.setCellSize(next)
+		}},
+		{"Zoom (Ctrl+=/-)", func() string { return fmt.Sprintf("%.0f%%", g.zoom()*100) }, func(dir int) {
+			next := g.zoom() + zoomStep*float64(dir)
+			if next > maxZoom {
+				next = minZoom
+			}
+			g.setZoom(next)
+		}},
+		{"Animations (Ctrl+Shift+A)", func() string { return onOff(g.animationsEnabled) }, func(int) { g.toggleAnimations() }},
+		{"Sound", func() string { return onOff(g.soundEnabled) + " (no sounds yet)" }, func(int) { g.soundEnabled = !g.soundEnabled }},
+	}
+}
+
+// toggleSettings opens or closes the settings panel.
+func (g *game) toggleSettings() {
+	g.showSettings = !g.showSettings
+	if g.showSettings {
+		g.showHelp = false
+		g.showScores = false
+		g.showReplays = false
+	}
+}
+
+// handleSettingsPanel runs the open settings panel: Up/Down pick a row,
+// Space/Enter or Right change it, Left steps back, Esc or Ctrl+O closes.
+func (g *game) handleSettingsPanel() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || ctrlKeyJustPressed(ebiten.KeyO) {
+		g.showSettings = false
+		return
+	}
+	rows := g.settings()
+	if keyRepeated(ebiten.KeyDown) {
+		g.settingsIdx = (g.settingsIdx + 1) % len(rows)
+	}
+	if keyRepeated(ebiten.KeyUp) {
+		g.settingsIdx = (g.settingsIdx + len(rows) - 1) % len(rows)
+	}
+	row := rows[clamp(g.settingsIdx, 0, len(rows)-1)]
+	switch {
+	case keyJustPressed(ebiten.KeySpace), keyJustPressed(ebiten.KeyEnter), keyRepeated(ebiten.KeyRight):
+		row.adjust(1)
+	case keyRepeated(ebiten.KeyLeft):
+		row.adjust(-1)
+	}
+}
+
+// drawSettingsPanel draws the settings list, sized to the number of rows,
+// with the focused row highlighted.
+func (g *game) drawSettingsPanel(screen *ebiten.Image, th theme) {
+	rows := g.settings()
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+	ebitenutil.DrawRect(screen, 0, 0, float64(w), float64(h), th.Overlay)
+	const rowH = 20
+	pw := min(420, w-36)
+	ph := min(68+len(rows)*rowH+rowH, h-36)
+	px, py := (w-pw)/2, (h-ph)/2
+	drawSunkenRect(screen, px, py, pw, ph, th)
+	ebitenutil.DrawRect(screen, float64(px+6), float64(py+6), float64(pw-12), float64(ph-12), th.Panel)
+
+	ff := basicfont.Face7x13
+	text.Draw(screen, "SETTINGS", ff, px+16, py+24, th.HeaderText)
+	y := py + 50
+	for i, r := range rows {
+		if i == g.settingsIdx {
+			hl := image.Rect(px+10, y-14, px+pw-10, y+5)
+			ebitenutil.DrawRect(screen, float64(hl.Min.X), float64(hl.Min.Y), float64(hl.Dx()), float64(hl.Dy()), withAlpha(th.Accent, 90))
+		}
+		text.Draw(screen, r.label, ff, px+16, y, th.HeaderText)
+		text.Draw(screen, r.value(), ff, px+pw/2+40, y, th.HeaderText)
+		y += rowH
+	}
+	text.Draw(screen, "Up/Down: select  Space/Enter: change  Esc: close", ff, px+16, y+6, th.HeaderTextSoft)
+}
+
+// toggleNoGuess switches no-guess mode. A layout already in play keeps its
+// mode until the next game.
+func (g *game) toggleNoGuess() {
+	g.NoGuess = !g.NoGuess
+	if !g.b.placed {
+		g.b.Solvable = g.NoGuess
+	}
+	g.clearHints()
+	g.showToast(fmt.Sprintf("No-guess mode: %v (from the next layout)", g.NoGuess))
+}
+
+// toggleNoFlags switches the no-flags variant and starts a new game under
+// it, so a game has flags throughout or not at all.
+func (g *game) toggleNoFlags() {
+	g.noFlags = !g.noFlags
+	g.reset(false)
+	if g.noFlags {
+		g.showToast("No-flags: reveal every safe cell without marking mines")
+	} else {
+		g.showToast("No-flags off")
+	}
+}
+
+func (g *game) toggleLeftHanded() {
+	g.leftHanded = !g.leftHanded
+	if g.leftHanded {
+		g.showToast("Left-handed: right button reveals")
+	} else {
+		g.showToast("Right-handed: left button reveals")
+	}
+}
+
+// toggleColorblind switches between the first accessible theme and
+// Classic.
+func (g *game) toggleColorblind() {
+	if themes[g.themeIdx].Accessible {
+		g.themeIdx = 0
+		return
+	}
+	for i, th := range themes {
+		if th.Accessible {
+			g.themeIdx = i
+			return
+		}
+	}
+}
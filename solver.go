@@ -0,0 +1,708 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ActionKind says what a deduction does to a cell.
+type ActionKind int
+
+const (
+	ActionReveal ActionKind = iota
+	ActionFlag
+)
+
+// Action is one cell a deduction reveals or flags.
+type Action struct {
+	Kind ActionKind
+	X, Y int
+}
+
+// DeductionStep is one logical move: the rule that was applied, the
+// numbers it was read from, and the cells it settles.
+type DeductionStep struct {
+	Rule    string
+	Sources []point
+	Actions []Action
+}
+
+// Solver replays a solved board from its first click using only what a
+// player could see at each point.
+type Solver struct {
+	b       *board
+	open    [][]bool
+	flagged [][]bool
+}
+
+// maxDeductionSteps guards against a runaway explanation on huge boards.
+const maxDeductionSteps = 2000
+
+// ExplainSolution lists the deductions that clear b starting from its first
+// click. It stops early when the rest can only be guessed; the final step
+// then says so and carries no actions.
+func (s *Solver) ExplainSolution(b *board) []DeductionStep {
+	if !b.placed || b.cells[b.start.Y][b.start.X].Mine {
+		return nil
+	}
+	s.b = b
+	s.open = make([][]bool, b.H)
+	s.flagged = make([][]bool, b.H)
+	for y := range s.open {
+		s.open[y] = make([]bool, b.W)
+		s.flagged[y] = make([]bool, b.W)
+	}
+
+	steps := []DeductionStep{{
+		Rule:    fmt.Sprintf("First click at (%d,%d)", b.start.X, b.start.Y),
+		Actions: []Action{{Kind: ActionReveal, X: b.start.X, Y: b.start.Y}},
+	}}
+	s.apply(steps[0])
+
+	for len(steps) < maxDeductionSteps && !s.done() {
+		step, ok := s.singleConstraint()
+		if !ok {
+			step, ok = s.subsetConstraint()
+		}
+		if !ok {
+			steps = append(steps, DeductionStep{Rule: "No safe deduction left: the rest needs a guess"})
+			break
+		}
+		s.apply(step)
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// unknowns returns the hidden, unflagged neighbours of (x,y) and how many
+// of its mines are still unaccounted for.
+func (s *Solver) unknowns(x, y int) ([]point, int) {
+	var cells []point
+	need := s.b.cells[y][x].Adjacent
+	s.b.around(x, y, func(nx, ny int) {
+		switch {
+		case s.flagged[ny][nx]:
+			need--
+		case !s.open[ny][nx]:
+			cells = append(cells, point{X: nx, Y: ny})
+		}
+	})
+	return cells, need
+}
+
+// singleConstraint looks for a number whose mines are all flagged, or whose
+// unknown neighbours must all be mines.
+func (s *Solver) singleConstraint() (DeductionStep, bool) {
+	for y := 0; y < s.b.H; y++ {
+		for x := 0; x < s.b.W; x++ {
+			if !s.open[y][x] || s.b.cells[y][x].Adjacent == 0 {
+				continue
+			}
+			cells, need := s.unknowns(x, y)
+			if len(cells) == 0 {
+				continue
+			}
+			src := []point{{X: x, Y: y}}
+			n := s.b.cells[y][x].Adjacent
+			switch need {
+			case 0:
+				return DeductionStep{
+					Rule:    fmt.Sprintf("Single constraint: (%d,%d) has %d mines, all flagged, so %d unknowns are safe", x, y, n, len(cells)),
+					Sources: src,
+					Actions: actionsFor(ActionReveal, cells),
+				}, true
+			case len(cells):
+				return DeductionStep{
+					Rule:    fmt.Sprintf("Single constraint: (%d,%d) has %d mines and %d unknowns", x, y, need, len(cells)),
+					Sources: src,
+					Actions: actionsFor(ActionFlag, cells),
+				}, true
+			}
+		}
+	}
+	return DeductionStep{}, false
+}
+
+// subsetConstraint compares pairs of nearby numbers. When the unknowns of A
+// all lie around B, the cells only B sees hold exactly needB-needA mines.
+func (s *Solver) subsetConstraint() (DeductionStep, bool) {
+	for ay := 0; ay < s.b.H; ay++ {
+		for ax := 0; ax < s.b.W; ax++ {
+			if !s.open[ay][ax] || s.b.cells[ay][ax].Adjacent == 0 {
+				continue
+			}
+			ua, na := s.unknowns(ax, ay)
+			if len(ua) == 0 {
+				continue
+			}
+			for by := ay - 2; by <= ay+2; by++ {
+				for bx := ax - 2; bx <= ax+2; bx++ {
+					if (bx == ax && by == ay) || !s.b.in(bx, by) || !s.open[by][bx] || s.b.cells[by][bx].Adjacent == 0 {
+						continue
+					}
+					ub, nb := s.unknowns(bx, by)
+					rest, ok := difference(ub, ua)
+					if !ok || len(rest) == 0 {
+						continue
+					}
+					m := nb - na
+					src := []point{{X: ax, Y: ay}, {X: bx, Y: by}}
+					switch m {
+					case 0:
+						return DeductionStep{
+							Rule:    fmt.Sprintf("Subset: (%d,%d) needs %d, (%d,%d) needs %d around the same cells, so its %d others are safe", ax, ay, na, bx, by, nb, len(rest)),
+							Sources: src,
+							Actions: actionsFor(ActionReveal, rest),
+						}, true
+					case len(rest):
+						return DeductionStep{
+							Rule:    fmt.Sprintf("Subset: (%d,%d) needs %d, (%d,%d) needs %d around the same cells, so its %d others are mines", ax, ay, na, bx, by, nb, len(rest)),
+							Sources: src,
+							Actions: actionsFor(ActionFlag, rest),
+						}, true
+					}
+				}
+			}
+		}
+	}
+	return DeductionStep{}, false
+}
+
+// apply records the step's actions, opening zeros the way board.reveal
+// would.
+func (s *Solver) apply(step DeductionStep) {
+	for _, a := range step.Actions {
+		if a.Kind == ActionFlag {
+			s.flagged[a.Y][a.X] = true
+			continue
+		}
+		queue := []point{{X: a.X, Y: a.Y}}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			if s.open[p.Y][p.X] || s.flagged[p.Y][p.X] {
+				continue
+			}
+			s.open[p.Y][p.X] = true
+			if s.b.cells[p.Y][p.X].Adjacent == 0 {
+				s.b.cascade(p.X, p.Y, func(nx, ny int) {
+					if !s.open[ny][nx] && !s.b.cells[ny][nx].Mine {
+						queue = append(queue, point{X: nx, Y: ny})
+					}
+				})
+			}
+		}
+	}
+}
+
+func (s *Solver) done() bool {
+	for y := 0; y < s.b.H; y++ {
+		for x := 0; x < s.b.W; x++ {
+			if !s.b.cells[y][x].Mine && !s.open[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func actionsFor(kind ActionKind, cells []point) []Action {
+	out := make([]Action, len(cells))
+	for i, c := range cells {
+		out[i] = Action{Kind: kind, X: c.X, Y: c.Y}
+	}
+	return out
+}
+
+// difference returns b minus a, and reports whether a is a subset of b.
+func difference(b, a []point) ([]point, bool) {
+	in := make(map[point]bool, len(b))
+	for _, p := range b {
+		in[p] = true
+	}
+	for _, p := range a {
+		if !in[p] {
+			return nil, false
+		}
+		delete(in, p)
+	}
+	var out []point
+	for _, p := range b {
+		if in[p] {
+			out = append(out, p)
+		}
+	}
+	return out, true
+}
+
+// SolveStep makes one pass of single-point constraints over the revealed
+// numbers, taking the player's flags as given. A number whose flags already
+// match it makes its other hidden neighbours safe; one whose missing mines
+// equal its hidden neighbours makes them all mines. It only reports the
+// cells and changes nothing; ok is false when nothing was deduced.
+func (b *board) SolveStep() (revealed, flagged [][2]int, ok bool) {
+	if !b.placed {
+		return nil, nil, false
+	}
+	seen := map[[2]int]bool{}
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			c := b.cells[y][x]
+			if !c.Revealed() || c.Mine || c.Adjacent == 0 {
+				continue
+			}
+			flags := 0
+			var hidden [][2]int
+			b.around(x, y, func(nx, ny int) {
+				nc := b.cells[ny][nx]
+				switch {
+				case nc.Flagged():
+					flags++
+				case !nc.Revealed():
+					hidden = append(hidden, [2]int{nx, ny})
+				}
+			})
+			if len(hidden) == 0 {
+				continue
+			}
+			switch c.Adjacent - flags {
+			case 0:
+				for _, p := range hidden {
+					if !seen[p] {
+						seen[p] = true
+						revealed = append(revealed, p)
+					}
+				}
+			case len(hidden):
+				for _, p := range hidden {
+					if !seen[p] {
+						seen[p] = true
+						flagged = append(flagged, p)
+					}
+				}
+			}
+		}
+	}
+	return revealed, flagged, len(revealed)+len(flagged) > 0
+}
+
+// AutoFlag flags the hidden neighbours of every revealed number whose
+// missing mines equal its unflagged hidden neighbours, repeating until a pass
+// finds nothing new. Like SolveStep it trusts the player's flags and never
+// looks at where the mines are. It returns the cells it flagged.
+func (b *board) AutoFlag() (flagged [][2]int) {
+	if !b.placed {
+		return nil
+	}
+	defer b.debugCheck("AutoFlag")
+	for {
+		_, mines, _ := b.SolveStep()
+		n := len(flagged)
+		for _, p := range mines {
+			if b.cells[p[1]][p[0]].Flag() {
+				b.flagsCnt++
+				flagged = append(flagged, p)
+			}
+		}
+		if len(flagged) == n {
+			return flagged
+		}
+	}
+}
+
+// ConstraintGroups splits the frontier into groups of logically linked
+// cells. Every revealed number with unmarked hidden neighbours constrains
+// them (their mines add up to what the number still misses); cells that
+// share a constraint, directly or through a chain of them, form one group.
+// Cells are given by row-major index y*W+x, each group in ascending order
+// and the groups ordered by their first cell.
+func (b *board) ConstraintGroups() [][]int {
+	parent := make([]int, b.W*b.H)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	inGroup := make([]bool, len(parent))
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if c := b.cells[y][x]; !c.Revealed() || c.Mine || c.Adjacent == 0 {
+				continue
+			}
+			first := -1
+			b.around(x, y, func(nx, ny int) {
+				if !b.cells[ny][nx].unmarked() {
+					return
+				}
+				i := ny*b.W + nx
+				inGroup[i] = true
+				if first < 0 {
+					first = i
+					return
+				}
+				// union by smallest index keeps each root the group's first cell
+				ri, rf := find(i), find(first)
+				parent[max(ri, rf)] = min(ri, rf)
+			})
+		}
+	}
+	var groups [][]int
+	slot := map[int]int{}
+	for i, on := range inGroup {
+		if !on {
+			continue
+		}
+		r := find(i)
+		k, ok := slot[r]
+		if !ok {
+			k = len(groups)
+			slot[r] = k
+			groups = append(groups, nil)
+		}
+		groups[k] = append(groups[k], i)
+	}
+	return groups
+}
+
+// IslandCount counts the connected regions of unmarked hidden cells,
+// touching diagonally included. Islands share no number, so each is solved
+// on its own: with one island left the rest is a single constraint system,
+// with several there may be a separate guess in each.
+func (b *board) IslandCount() int {
+	seen := make([][]bool, b.H)
+	for y := range seen {
+		seen[y] = make([]bool, b.W)
+	}
+	islands := 0
+	for y := 0; y < b.H; y++ {
+		for x := 0; x < b.W; x++ {
+			if seen[y][x] || !b.cells[y][x].unmarked() {
+				continue
+			}
+			islands++
+			seen[y][x] = true
+			stack := []point{{X: x, Y: y}}
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				b.around(p.X, p.Y, func(nx, ny int) {
+					if !seen[ny][nx] && b.cells[ny][nx].unmarked() {
+						seen[ny][nx] = true
+						stack = append(stack, point{X: nx, Y: ny})
+					}
+				})
+			}
+		}
+	}
+	return islands
+}
+
+// MineHeatmap estimates the chance of a mine in every cell from what the
+// player can see. Hidden cells start at the remaining mines over the hidden
+// cells. The numbers are then applied until nothing changes: a number whose
+// mines are all accounted for clears its other hidden neighbours, one that
+// needs all of them makes them mines. Frontier cells still open take the
+// highest share any of their numbers leaves them, and the mines those are
+// expected to hold come off the density of the cells no number touches.
+// Revealed cells are 0 and flagged cells 1.
+func (b *board) MineHeatmap() [][]float64 {
+	const unknown, safe, mine = -1, 0, 1
+	known := make([][]int, b.H)
+	heat := make([][]float64, b.H)
+	hidden := 0
+	for y := range known {
+		known[y] = make([]int, b.W)
+		heat[y] = make([]float64, b.W)
+		for x := range known[y] {
+			c := b.cells[y][x]
+			switch {
+			case c.Revealed() && c.Mine, c.Flagged():
+				known[y][x] = mine
+			case c.Revealed():
+				known[y][x] = safe
+			default:
+				known[y][x] = unknown
+				hidden++
+			}
+		}
+	}
+	if hidden == 0 {
+		for y := range heat {
+			for x := range heat[y] {
+				heat[y][x] = float64(known[y][x])
+			}
+		}
+		return heat
+	}
+	base := math.Min(1, math.Max(0, float64(b.remainingMines())/float64(hidden)))
+
+	// numbers visits every revealed number with the mines it still needs
+	// and its neighbours not yet known
+	numbers := func(fn func(left int, open [][2]int)) {
+		for y := 0; y < b.H; y++ {
+			for x := 0; x < b.W; x++ {
+				if c := b.cells[y][x]; !c.Revealed() || c.Mine || c.Adjacent == 0 {
+					continue
+				}
+				left := b.cells[y][x].Adjacent
+				var open [][2]int
+				b.around(x, y, func(nx, ny int) {
+					switch known[ny][nx] {
+					case mine:
+						left--
+					case unknown:
+						open = append(open, [2]int{nx, ny})
+					}
+				})
+				if len(open) > 0 {
+					fn(left, open)
+				}
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		numbers(func(left int, open [][2]int) {
+			v := unknown
+			switch {
+			case left <= 0:
+				v = safe
+			case left >= len(open):
+				v = mine
+			}
+			if v == unknown {
+				return
+			}
+			for _, p := range open {
+				known[p[1]][p[0]] = v
+			}
+			changed = true
+		})
+	}
+
+	frontier := map[[2]int]float64{}
+	numbers(func(left int, open [][2]int) {
+		share := float64(left) / float64(len(open))
+		for _, p := range open {
+			frontier[p] = math.Max(frontier[p], share)
+		}
+	})
+	minesLeft := float64(b.Mines)
+	interior := 0
+	for y := range known {
+		for x, k := range known[y] {
+			_, onFrontier := frontier[[2]int{x, y}]
+			switch {
+			case k == mine:
+				minesLeft--
+			case k == unknown && !onFrontier:
+				interior++
+			}
+		}
+	}
+	for _, share := range frontier {
+		minesLeft -= share
+	}
+	if interior > 0 {
+		base = math.Min(1, math.Max(0, minesLeft/float64(interior)))
+	}
+	for y := range heat {
+		for x, k := range known[y] {
+			share, onFrontier := frontier[[2]int{x, y}]
+			switch {
+			case k != unknown:
+				heat[y][x] = float64(k)
+			case onFrontier:
+				heat[y][x] = share
+			default:
+				heat[y][x] = base
+			}
+		}
+	}
+	return heat
+}
+
+// IsSolvable plays the layout from its first click on a scratch board using
+// only SolveStep and reports whether that clears it without a guess.
+func (b *board) IsSolvable() bool {
+	if !b.placed {
+		return false
+	}
+	sim := &board{W: b.W, H: b.H, Mines: b.Mines, ConnectivityMode: b.ConnectivityMode, Toroidal: b.Toroidal, placed: true, start: b.start}
+	sim.cells = make([][]cell, b.H)
+	for y := range sim.cells {
+		sim.cells[y] = make([]cell, b.W)
+		for x := range sim.cells[y] {
+			sim.cells[y][x].Mine = b.cells[y][x].Mine
+			sim.cells[y][x].Adjacent = b.cells[y][x].Adjacent
+		}
+	}
+	if hit, _ := sim.reveal(b.start.X, b.start.Y); hit {
+		return false
+	}
+	return sim.runSolver()
+}
+
+// runSolver plays SolveStep's deductions on the board itself until it is
+// won or nothing more follows, and reports whether it was won.
+func (b *board) runSolver() bool {
+	for !b.isWin() {
+		revealed, flagged, ok := b.SolveStep()
+		if !ok {
+			return false
+		}
+		for _, p := range flagged {
+			if b.cells[p[1]][p[0]].Flag() {
+				b.flagsCnt++
+			}
+		}
+		for _, p := range revealed {
+			b.reveal(p[0], p[1])
+		}
+	}
+	return true
+}
+
+// DeducedMines counts the hidden cells AutoFlag would prove to be mines,
+// working on a scratch board without the player's marks so it also counts
+// for games played without flags.
+func (b *board) DeducedMines() int {
+	if !b.placed {
+		return 0
+	}
+	sim := &board{W: b.W, H: b.H, Mines: b.Mines, ConnectivityMode: b.ConnectivityMode, Toroidal: b.Toroidal, placed: true, start: b.start}
+	sim.cells = make([][]cell, b.H)
+	for y := range sim.cells {
+		sim.cells[y] = make([]cell, b.W)
+		for x := range sim.cells[y] {
+			c := b.cells[y][x]
+			sim.cells[y][x] = cell{Mine: c.Mine, Adjacent: c.Adjacent}
+			if c.Revealed() {
+				sim.cells[y][x].State = c.State
+				if !c.Mine {
+					sim.revealedCnt++
+				}
+			}
+		}
+	}
+	return len(sim.AutoFlag())
+}
+
+// autoFlag runs AutoFlag for the player as a single undo step.
+func (g *game) autoFlag() {
+	if g.noFlags {
+		g.showToast("No flags in this variant")
+		return
+	}
+	if g.state != statePlaying || g.paused || !g.b.placed || len(g.revealQueue) > 0 {
+		return
+	}
+	n := g.applyAutoFlags(g.b.AutoFlag)
+	if n == 0 {
+		g.showToast("No mines to flag")
+		return
+	}
+	g.showToast(fmt.Sprintf("Auto-flagged %d mines", n))
+}
+
+// autoFlagSingles runs AutoFlagSingles for the player as a single undo step.
+func (g *game) autoFlagSingles() {
+	if g.noFlags || g.state != statePlaying || g.paused || !g.b.placed || len(g.revealQueue) > 0 {
+		return
+	}
+	n := g.applyAutoFlags(g.b.AutoFlagSingles)
+	g.showToast(fmt.Sprintf("Auto-flagged %d obvious mines", n))
+}
+
+// applyAutoFlags runs flag, one of the board's auto-flaggers, and books the
+// flags it placed like the player's own: one undo step, a replay move each
+// and a wrong flag noted for every safe cell. It returns how many it placed.
+func (g *game) applyAutoFlags(flag func() [][2]int) int {
+	before := g.b.snapshot()
+	flagged := flag()
+	if len(flagged) == 0 {
+		return 0
+	}
+	g.pushUndo(before)
+	for _, p := range flagged {
+		if !g.b.cells[p[1]][p[0]].Mine {
+			g.noteWrongFlag(p[0], p[1])
+		}
+		g.events.Publish(MoveEvent{Action: ReplaySetFlag, X: p[0], Y: p[1]})
+	}
+	g.clearHints()
+	return len(flagged)
+}
+
+// autoSolveAll plays SolveStep's deductions until it finds nothing more.
+// The whole run is one undo step, and the game no longer counts for
+// records.
+func (g *game) autoSolveAll() {
+	if g.noFlags {
+		// the solver needs its mines flagged to get further
+		g.showToast("No flags in this variant")
+		return
+	}
+	if g.state != statePlaying || g.paused || !g.b.placed || len(g.revealQueue) > 0 {
+		return
+	}
+	before := g.b.snapshot()
+	moves := 0
+	for {
+		revealed, flagged, ok := g.b.SolveStep()
+		if !ok {
+			break
+		}
+		for _, p := range flagged {
+			c := &g.b.cells[p[1]][p[0]]
+			// a question mark has to go before the flag
+			c.Unflag()
+			if c.Flag() {
+				g.b.flagsCnt++
+				if !c.Mine {
+					g.noteWrongFlag(p[0], p[1])
+				}
+				g.events.Publish(MoveEvent{Action: ReplaySetFlag, X: p[0], Y: p[1]})
+			}
+		}
+		moves += len(flagged)
+		hit := false
+		for _, p := range revealed {
+			if g.b.cells[p[1]][p[0]].Revealed() {
+				// already opened by an earlier cascade in this pass
+				continue
+			}
+			g.events.Publish(MoveEvent{Action: ReplayReveal, X: p[0], Y: p[1]})
+			moves++
+			if h, _ := g.b.reveal(p[0], p[1]); h {
+				hit = true
+				break
+			}
+		}
+		if hit {
+			g.pushUndo(before)
+			g.assisted = true
+			g.onGameLost()
+			return
+		}
+		if g.b.isWin() {
+			break
+		}
+	}
+	if moves == 0 {
+		g.showToast("Nothing to deduce")
+		return
+	}
+	g.pushUndo(before)
+	g.assisted = true
+	g.clearHints()
+	g.showToast(fmt.Sprintf("Auto-solved %d moves", moves))
+	if g.b.isWin() {
+		g.onGameWon()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainSolution(t *testing.T) {
+	rows := []string{
+		".........",
+		".*.......",
+		".........",
+		"......*..",
+		".........",
+		"..*......",
+		"........*",
+		"....*....",
+		"*.....*.*",
+	}
+	tests := []struct {
+		name      string
+		start     point
+		wantSteps int
+	}{
+		{"click in the middle", point{X: 4, Y: 3}, 3},
+		{"click in a corner", point{X: 8, Y: 0}, 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testBoard(t, rows...)
+			b.start = tc.start
+			steps := new(Solver).ExplainSolution(b)
+			if len(steps) < tc.wantSteps {
+				t.Fatalf("%d steps, want at least %d", len(steps), tc.wantSteps)
+			}
+			if !strings.HasPrefix(steps[0].Rule, "First click") {
+				t.Fatalf("first step %q, want the first click", steps[0].Rule)
+			}
+			deduced := 0
+			for i, st := range steps {
+				if st.Rule == "" {
+					t.Fatalf("step %d has no rule", i)
+				}
+				for _, a := range st.Actions {
+					mine := b.cells[a.Y][a.X].Mine
+					if a.Kind == ActionFlag && !mine || a.Kind == ActionReveal && mine {
+						t.Fatalf("step %d (%s) gets (%d,%d) wrong", i, st.Rule, a.X, a.Y)
+					}
+				}
+				if i > 0 && len(st.Actions) > 0 {
+					deduced++
+					if len(st.Sources) == 0 {
+						t.Fatalf("step %d (%s) names no numbers", i, st.Rule)
+					}
+				}
+			}
+			if deduced < 2 {
+				t.Fatalf("%d deductions after the first click, want at least 2", deduced)
+			}
+			// the layout is unchanged by explaining it
+			for y := range b.cells {
+				for x := range b.cells[y] {
+					if b.cells[y][x].State != StateHidden {
+						t.Fatalf("cell (%d,%d) was touched", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGameAutoFlagSingles(t *testing.T) {
+	tests := []struct {
+		name      string
+		noFlags   bool
+		open      bool
+		wantFlags int
+	}{
+		{"flags every lone candidate", false, true, 3},
+		{"nothing open, nothing to flag", false, false, 0},
+		{"no flags variant", true, true, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newHeadlessGame()
+			g.b = testBoard(t, "*...*...*", ".........")
+			g.noFlags = tc.noFlags
+			if tc.open {
+				for y := 0; y < g.b.H; y++ {
+					for x := 0; x < g.b.W; x++ {
+						if !g.b.cells[y][x].Mine {
+							openCell(t, g.b, x, y)
+						}
+					}
+				}
+			}
+			g.autoFlagSingles()
+			if g.b.flagsCnt != tc.wantFlags {
+				t.Fatalf("flagsCnt = %d, want %d", g.b.flagsCnt, tc.wantFlags)
+			}
+			if got := len(g.replayEvents); got != tc.wantFlags {
+				t.Fatalf("recorded %d moves, want %d", got, tc.wantFlags)
+			}
+			wantUndo := 0
+			if tc.wantFlags > 0 {
+				wantUndo = 1
+			}
+			if len(g.undo) != wantUndo {
+				t.Fatalf("undo depth = %d, want %d", len(g.undo), wantUndo)
+			}
+			if g.undoMove() && g.b.flagsCnt != 0 {
+				t.Fatalf("flagsCnt after undo = %d, want 0", g.b.flagsCnt)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Stats holds the per-score-key records that go beyond the best time kept in
+// scores.json.
+type Stats struct {
+	BestWeightedScore float64 `json:"best_weighted_score"`
+	GamesPlayed       int     `json:"games_played"`
+	GamesWon          int     `json:"games_won"`
+	TotalTimeMs       int64   `json:"total_time_ms"`
+	BestTimeMs        int64   `json:"best_time_ms"`
+	CurrentStreak     int     `json:"current_streak"`
+	BestStreak        int     `json:"best_streak"`
+	Best3BVPerSec     float64 `json:"best_3bv_per_sec,omitempty"`
+	// AvgBVPerSec is the player's usual pace, a moving average of the
+	// 3BV/s of recent wins.
+	AvgBVPerSec float64 `json:"avg_3bv_per_sec,omitempty"`
+	LeftClicks  int     `json:"left_clicks,omitempty"`
+	RightClicks int     `json:"right_clicks,omitempty"`
+	ChordClicks int     `json:"chord_clicks,omitempty"`
+	// WrongFlags counts every flag put on a safe cell across all games;
+	// BestCleanTimeMs is the best fair win that never placed one.
+	WrongFlags      int   `json:"wrong_flags,omitempty"`
+	BestCleanTimeMs int64 `json:"best_clean_time_ms,omitempty"`
+	// BestMarathonMs is the lowest marathon total.
+	BestMarathonMs int64 `json:"best_marathon_ms,omitempty"`
+	// Daily* remember the last daily challenge under a daily key, so it
+	// can only be played once per day.
+	DailyDate   string `json:"daily_date,omitempty"`
+	DailyWon    bool   `json:"daily_won,omitempty"`
+	DailyTimeMs int64  `json:"daily_time_ms,omitempty"`
+}
+
+// recordWin counts a won game. Only fair games (see onGameWon) may set
+// BestTimeMs.
+func (st *Stats) recordWin(elapsed time.Duration, fair bool) {
+	ms := elapsed.Milliseconds()
+	st.GamesPlayed++
+	st.GamesWon++
+	st.TotalTimeMs += ms
+	if fair && (st.BestTimeMs == 0 || ms < st.BestTimeMs) {
+		st.BestTimeMs = ms
+	}
+	st.CurrentStreak++
+	st.BestStreak = max(st.BestStreak, st.CurrentStreak)
+}
+
+func (st *Stats) recordLoss() {
+	st.GamesPlayed++
+	st.CurrentStreak = 0
+}
+
+// paceWeight is how much one win moves AvgBVPerSec.
+const paceWeight = 0.2
+
+// recordPace folds a win's 3BV/s into AvgBVPerSec. The first win sets it
+// outright rather than averaging against zero.
+func (st *Stats) recordPace(bvPerSec float64) {
+	if st.AvgBVPerSec == 0 {
+		st.AvgBVPerSec = bvPerSec
+		return
+	}
+	st.AvgBVPerSec = (1-paceWeight)*st.AvgBVPerSec + paceWeight*bvPerSec
+}
+
+// paceNote is what the win banner says about a win played at bvPerSec,
+// judged against the average before it is counted.
+func paceNote(st *Stats, bvPerSec float64) string {
+	if st.AvgBVPerSec == 0 || bvPerSec <= st.AvgBVPerSec {
+		return ""
+	}
+	return "Your pace was on track for a PB on a typical board."
+}
+
+// recordClicks adds one finished game's clicks to the totals.
+func (st *Stats) recordClicks(left, right, chord int) {
+	st.LeftClicks += left
+	st.RightClicks += right
+	st.ChordClicks += chord
+}
+
+// recordFlagAccuracy adds a won game's wrong flags to the total and keeps
+// its time when it was a fair win without any.
+func (st *Stats) recordFlagAccuracy(wrong int, elapsed time.Duration, fair bool) {
+	st.WrongFlags += wrong
+	ms := elapsed.Milliseconds()
+	if wrong == 0 && fair && (st.BestCleanTimeMs == 0 || ms < st.BestCleanTimeMs) {
+		st.BestCleanTimeMs = ms
+	}
+}
+
+// summary is the one-line digest shown under a key in the scores overlay.
+func (st *Stats) summary() string {
+	if st.GamesPlayed == 0 {
+		return ""
+	}
+	line := fmt.Sprintf("won %d/%d (%.0f%%)", st.GamesWon, st.GamesPlayed, float64(st.GamesWon)*100/float64(st.GamesPlayed))
+	if st.GamesWon > 0 {
+		avg := time.Duration(st.TotalTimeMs/int64(st.GamesWon)) * time.Millisecond
+		line += "  avg " + formatDuration(avg)
+	}
+	line += fmt.Sprintf("  streak %d (best %d)", st.CurrentStreak, st.BestStreak)
+	if st.LeftClicks+st.RightClicks+st.ChordClicks > 0 {
+		line += fmt.Sprintf("  clicks L/R/C %d/%d/%d", st.LeftClicks, st.RightClicks, st.ChordClicks)
+	}
+	line += fmt.Sprintf("  wrong flags %d", st.WrongFlags)
+	if st.BestMarathonMs > 0 {
+		line += "  marathon " + formatDuration(time.Duration(st.BestMarathonMs)*time.Millisecond)
+	}
+	if st.BestCleanTimeMs > 0 {
+		line += "  best with no wrong flags " + formatDuration(time.Duration(st.BestCleanTimeMs)*time.Millisecond)
+	}
+	return line
+}
+
+// resetStats forgets everything recorded for one score key.
+func (g *game) resetStats(key string) {
+	delete(g.stats, key)
+	saveStats(g.stats)
+}
+
+func (g *game) statsFor(key string) *Stats {
+	st := g.stats[key]
+	if st == nil {
+		st = &Stats{}
+		g.stats[key] = st
+	}
+	return st
+}
+
+func statsFilePath() string {
+	return configFilePath("stats.json")
+}
+
+func loadStats() map[string]*Stats {
+	data, err := os.ReadFile(statsFilePath())
+	if err != nil {
+		return map[string]*Stats{}
+	}
+	var out map[string]*Stats
+	if err := json.Unmarshal(data, &out); err != nil || out == nil {
+		return map[string]*Stats{}
+	}
+	return out
+}
+
+func saveStats(stats map[string]*Stats) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statsFilePath(), data, 0o644)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// telemetryFlushEvery is how often buffered telemetry reaches the file.
+const telemetryFlushEvery = 30 * time.Second
+
+// noTelemetry is set by --no-telemetry.
+var noTelemetry bool
+
+// TelemetryEvent is one line of telemetry.jsonl.
+type TelemetryEvent struct {
+	Time    time.Time `json:"ts"`
+	Type    string    `json:"type"`
+	Payload any       `json:"payload,omitempty"`
+}
+
+// Telemetry keeps a local, append-only record of how the game is played in
+// telemetry.jsonl, one JSON event per line. Nothing leaves the machine.
+// Events are buffered and written every telemetryFlushEvery and on exit. A
+// nil *Telemetry records nothing.
+type Telemetry struct {
+	path      string
+	buf       []TelemetryEvent
+	lastFlush time.Time
+}
+
+func newTelemetry(path string) *Telemetry {
+	return &Telemetry{path: path, lastFlush: time.Now()}
+}
+
+// Record buffers an event.
+func (t *Telemetry) Record(typ string, payload any) {
+	if t == nil {
+		return
+	}
+	t.buf = append(t.buf, TelemetryEvent{Time: time.Now(), Type: typ, Payload: payload})
+}
+
+// tick flushes once telemetryFlushEvery has passed since the last flush.
+func (t *Telemetry) tick(now time.Time) {
+	if t == nil || now.Sub(t.lastFlush) < telemetryFlushEvery {
+		return
+	}
+	if err := t.Flush(); err != nil {
+		debugf("telemetry: %v", err)
+	}
+}
+
+// Flush appends the buffered events to the file.
+func (t *Telemetry) Flush() error {
+	if t == nil {
+		return nil
+	}
+	t.lastFlush = time.Now()
+	if len(t.buf) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range t.buf {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	t.buf = t.buf[:0]
+	return f.Close()
+}
+
+// startTelemetry records the game's events to telemetry.jsonl.
+func (g *game) startTelemetry() {
+	t := newTelemetry(configFilePath("telemetry.jsonl"))
+	g.telemetry = t
+	g.events.Subscribe(EventGameStarted, func(Event) {
+		t.Record("game_start", map[string]any{"key": g.scoreKey(), "seed": g.b.seed})
+	})
+	g.events.Subscribe(EventGameWon, func(e Event) {
+		if g.playback != nil {
+			return
+		}
+		t.Record("game_end", map[string]any{"key": g.scoreKey(), "outcome": "won", "duration_ms": e.(GameWonEvent).Elapsed.Milliseconds()})
+	})
+	g.events.Subscribe(EventGameLost, func(e Event) {
+		if g.playback != nil {
+			return
+		}
+		t.Record("game_end", map[string]any{"key": g.scoreKey(), "outcome": "lost", "duration_ms": e.(GameLostEvent).Elapsed.Milliseconds()})
+	})
+	g.events.Subscribe(EventHintUsed, func(e Event) {
+		t.Record("hint_used", map[string]any{"reason": e.(HintUsedEvent).Reason})
+	})
+	g.events.Subscribe(EventChordUsed, func(e Event) {
+		ev := e.(ChordUsedEvent)
+		t.Record("chord_used", map[string]any{"x": ev.X, "y": ev.Y, "opened": ev.Opened})
+	})
+	g.events.Subscribe(EventDifficulty, func(e Event) {
+		t.Record("difficulty_changed", map[string]any{"name": e.(DifficultyChangedEvent).Name})
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// themeJSON is the on-disk form of a theme in themes.json. Colours are
+// "#RRGGBB", or "#RRGGBBAA" where transparency matters.
+type themeJSON struct {
+	Name           string   `json:"name"`
+	BG             string   `json:"bg"`
+	Panel          string   `json:"panel"`
+	Light          string   `json:"light"`
+	Dark           string   `json:"dark"`
+	CellHidden     string   `json:"cell_hidden"`
+	CellRevealed   string   `json:"cell_revealed"`
+	CellGrid       string   `json:"cell_grid"`
+	CellText       string   `json:"cell_text"`
+	Mine           string   `json:"mine"`
+	Flag           string   `json:"flag"`
+	WrongFlag      string   `json:"wrong_flag"`
+	Accent         string   `json:"accent"`
+	Overlay        string   `json:"overlay"`
+	Digit          string   `json:"digit"`
+	HeaderText     string   `json:"header_text"`
+	HeaderTextSoft string   `json:"header_text_soft"`
+	NumberColors   []string `json:"number_colors,omitempty"`
+	Accessible     bool     `json:"accessible,omitempty"`
+}
+
+func themesFilePath() string {
+	return configFilePath("themes.json")
+}
+
+// loadThemes reads the user's themes from themes.json. On first run there
+// is no file: the built-in themes are written there as a starting point and
+// nil is returned. A theme with a bad colour is skipped.
+func loadThemes() []theme {
+	data, err := os.ReadFile(themesFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		saveDefaultThemes()
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	var defs []themeJSON
+	if err := json.Unmarshal(data, &defs); err != nil {
+		debugf("themes.json: %v", err)
+		return nil
+	}
+	var out []theme
+	for _, d := range defs {
+		th, err := d.theme()
+		if err != nil {
+			debugf("themes.json: %v", err)
+			continue
+		}
+		out = append(out, th)
+	}
+	return out
+}
+
+// saveDefaultThemes writes the built-in themes to themes.json.
+func saveDefaultThemes() error {
+	defs := make([]themeJSON, len(themes))
+	for i, th := range themes {
+		defs[i] = newThemeJSON(th)
+	}
+	data, err := json.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(themesFilePath(), data, 0o644)
+}
+
+// mergeThemes adds the loaded themes to the built-in ones. A loaded theme
+// named like a built-in one replaces it, so the file written on first run
+// edits the built-in themes rather than duplicating them.
+func mergeThemes(builtin, loaded []theme) []theme {
+	out := append([]theme(nil), builtin...)
+next:
+	for _, th := range loaded {
+		for i := range out {
+			if out[i].Name == th.Name {
+				out[i] = th
+				continue next
+			}
+		}
+		out = append(out, th)
+	}
+	return out
+}
+
+func newThemeJSON(th theme) themeJSON {
+	d := themeJSON{
+		Name:           th.Name,
+		BG:             hexColor(th.BG),
+		Panel:          hexColor(th.Panel),
+		Light:          hexColor(th.Light),
+		Dark:           hexColor(th.Dark),
+		CellHidden:     hexColor(th.CellHidden),
+		CellRevealed:   hexColor(th.CellRevealed),
+		CellGrid:       hexColor(th.CellGrid),
+		CellText:       hexColor(th.CellText),
+		Mine:           hexColor(th.Mine),
+		Flag:           hexColor(th.Flag),
+		WrongFlag:      hexColor(th.WrongFlag),
+		Accent:         hexColor(th.Accent),
+		Overlay:        hexColor(th.Overlay),
+		Digit:          hexColor(th.Digit),
+		HeaderText:     hexColor(th.HeaderText),
+		HeaderTextSoft: hexColor(th.HeaderTextSoft),
+		Accessible:     th.Accessible,
+	}
+	for _, c := range th.NumberColors {
+		d.NumberColors = append(d.NumberColors, hexColor(c))
+	}
+	return d
+}
+
+// theme converts a definition back into a theme. Without number colours it
+// borrows the Classic palette.
+func (d themeJSON) theme() (theme, error) {
+	var err error
+	parse := func(s string) color.Color {
+		c, e := parseHexColor(s)
+		if e != nil && err == nil {
+			err = fmt.Errorf("theme %q: %w", d.Name, e)
+		}
+		return c
+	}
+	th := theme{
+		Name:           d.Name,
+		BG:             parse(d.BG),
+		Panel:          parse(d.Panel),
+		Light:          parse(d.Light),
+		Dark:           parse(d.Dark),
+		CellHidden:     parse(d.CellHidden),
+		CellRevealed:   parse(d.CellRevealed),
+		CellGrid:       parse(d.CellGrid),
+		CellText:       parse(d.CellText),
+		Mine:           parse(d.Mine),
+		Flag:           parse(d.Flag),
+		WrongFlag:      parse(d.WrongFlag),
+		Accent:         parse(d.Accent),
+		Overlay:        parse(d.Overlay),
+		Digit:          parse(d.Digit),
+		HeaderText:     parse(d.HeaderText),
+		HeaderTextSoft: parse(d.HeaderTextSoft),
+		NumberColors:   themes[0].NumberColors,
+		Accessible:     d.Accessible,
+	}
+	switch len(d.NumberColors) {
+	case 0:
+	case len(th.NumberColors):
+		for i, s := range d.NumberColors {
+			th.NumberColors[i] = parse(s)
+		}
+	default:
+		return theme{}, fmt.Errorf("theme %q: %d number colours, want %d", d.Name, len(d.NumberColors), len(th.NumberColors))
+	}
+	if d.Name == "" && err == nil {
+		err = errors.New("theme without a name")
+	}
+	return th, err
+}
+
+// hexColor formats c as "#RRGGBB", adding the alpha only when it is not
+// opaque.
+func hexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+func parseHexColor(s string) (color.Color, error) {
+	h, ok := strings.CutPrefix(s, "#")
+	if !ok || len(h) != 6 && len(h) != 8 {
+		return nil, fmt.Errorf("bad colour %q, want #RRGGBB", s)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("bad colour %q, want #RRGGBB", s)
+	}
+	if len(h) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// tournamentRounds is how many boards a tournament plays, from Beginner up
+// to Expert.
+const tournamentRounds = 6
+
+// TournamentRound is one board of a tournament and how it went.
+type TournamentRound struct {
+	Seed       int64      `json:"seed"`
+	Difficulty difficulty `json:"difficulty"`
+	Played     bool       `json:"played"`
+	Won        bool       `json:"won"`
+	ElapsedMs  int64      `json:"elapsed_ms"`
+	Clicks     int        `json:"clicks"`
+}
+
+// Tournament is a fixed list of seeded boards drawn from one master seed,
+// so every run of the same tournament plays the same layouts (the first
+// click still decides where the safe opening is) and runs can be compared.
+type Tournament struct {
+	MasterSeed int64             `json:"master_seed"`
+	Started    time.Time         `json:"started"`
+	Rounds     []TournamentRound `json:"rounds"`
+}
+
+func newTournament(master int64) *Tournament {
+	rng := rand.New(rand.NewSource(master))
+	t := &Tournament{MasterSeed: master, Started: time.Now()}
+	for i := 0; i < tournamentRounds; i++ {
+		t.Rounds = append(t.Rounds, TournamentRound{
+			Seed:       rng.Int63(),
+			Difficulty: presets[i*3/tournamentRounds],
+		})
+	}
+	return t
+}
+
+// next is the index of the first round not yet played, len(Rounds) once
+// all are.
+func (t *Tournament) next() int {
+	for i, r := range t.Rounds {
+		if !r.Played {
+			return i
+		}
+	}
+	return len(t.Rounds)
+}
+
+// totals are the rounds won and the time they took.
+func (t *Tournament) totals() (won int, elapsed time.Duration) {
+	for _, r := range t.Rounds {
+		if r.Won {
+			won++
+			elapsed += time.Duration(r.ElapsedMs) * time.Millisecond
+		}
+	}
+	return won, elapsed
+}
+
+// ranksAbove reports whether t places ahead of o: more rounds won, then
+// less time.
+func (t *Tournament) ranksAbove(o *Tournament) bool {
+	tw, te := t.totals()
+	ow, oe := o.totals()
+	if tw != ow {
+		return tw > ow
+	}
+	return te < oe
+}
+
+// tournamentFilePath holds every finished run of the day's tournament.
+func tournamentFilePath(day time.Time) string {
+	return configFilePath("tournament_" + day.Format("20060102") + ".json")
+}
+
+func loadTournamentRuns(path string) []*Tournament {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var runs []*Tournament
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil
+	}
+	return runs
+}
+
+// saveTournament adds a finished run to the day's file.
+func saveTournament(t *Tournament) error {
+	path := tournamentFilePath(t.Started)
+	runs := append(loadTournamentRuns(path), t)
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// toggleTournament starts today's tournament, or abandons the one in
+// progress. The master seed is the date, so a tournament played on the
+// same day always has the same boards.
+func (g *game) toggleTournament() {
+	if g.tournament != nil {
+		g.tournament = nil
+		g.showToast("Tournament abandoned")
+		g.reset(false)
+		return
+	}
+	g.marathonMode = false
+	g.tournament = newTournament(dailySeed(time.Now()))
+	g.startTournamentRound()
+	g.showToast(fmt.Sprintf("Tournament: %d rounds, same boards for every run today", tournamentRounds))
+}
+
+// startTournamentRound sets up the next round's difficulty and seed.
+func (g *game) startTournamentRound() {
+	r := g.tournament.Rounds[g.tournament.next()]
+	if g.diff != r.Difficulty {
+		g.setDifficulty(r.Difficulty)
+	} else {
+		g.reset(false)
+	}
+	g.seedTournamentRound()
+}
+
+// seedTournamentRound gives a fresh board the current round's seed, so
+// starting a round over keeps its layout. A board of another size is left
+// alone.
+func (g *game) seedTournamentRound() {
+	t := g.tournament
+	if t == nil || g.b.placed && !g.b.pregenerated {
+		return
+	}
+	i := t.next()
+	if i == len(t.Rounds) || g.diff != t.Rounds[i].Difficulty {
+		return
+	}
+	if g.b.pregenerated {
+		g.b.clearMines()
+	}
+	g.b.SetSeed(t.Rounds[i].Seed)
+}
+
+// tournamentFinish records a finished round. The next round follows on the
+// next frame; after the last one the run is saved and the summary opens.
+func (g *game) tournamentFinish(won bool) {
+	t := g.tournament
+	if t == nil || g.playback != nil || g.headless {
+		return
+	}
+	i := t.next()
+	if i == len(t.Rounds) || g.diff != t.Rounds[i].Difficulty || g.b.seed != t.Rounds[i].Seed {
+		// a board the player switched to is not part of the tournament
+		return
+	}
+	r := &t.Rounds[i]
+	r.Played, r.Won = true, won
+	r.ElapsedMs = g.elapsed.Milliseconds()
+	r.Clicks = g.clickCount()
+	if i+1 < len(t.Rounds) {
+		g.tournamentNext = true
+		return
+	}
+	if err := saveTournament(t); err != nil {
+		g.showToast("Tournament not saved: " + err.Error())
+	}
+	g.showTournament = true
+}
+
+// handleTournamentSummary closes the summary with Enter or Esc and ends
+// the tournament.
+func (g *game) handleTournamentSummary() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyEnter) && !inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		return
+	}
+	g.showTournament = false
+	g.tournament = nil
+	g.reset(false)
+}
+
+// tournamentStatus is the tournament's progress for the top panel.
+func (g *game) tournamentStatus() string {
+	t := g.tournament
+	return fmt.Sprintf("[Tournament %d/%d]", min(t.next()+1, len(t.Rounds)), len(t.Rounds))
+}
+
+// tournamentLines is the summary table, followed by where this run ranks
+// among today's.
+func (g *game) tournamentLines() []string {
+	t := g.tournament
+	lines := []string{"Round  Difficulty    Result  Time      Clicks"}
+	for i, r := range t.Rounds {
+		result, elapsed := "lost", "-"
+		if r.Won {
+			result = "won"
+			elapsed = formatDuration(time.Duration(r.ElapsedMs) * time.Millisecond)
+		}
+		lines = append(lines, fmt.Sprintf("%-6d %-13s %-7s %-9s %d", i+1, r.Difficulty.Name, result, elapsed, r.Clicks))
+	}
+	won, elapsed := t.totals()
+	lines = append(lines, fmt.Sprintf("Won %d/%d in %s", won, len(t.Rounds), formatDuration(elapsed)))
+
+	runs := loadTournamentRuns(tournamentFilePath(t.Started))
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].ranksAbove(runs[j]) })
+	for i, run := range runs {
+		if run.Started.Equal(t.Started) {
+			lines = append(lines, fmt.Sprintf("Rank %d of %d runs today", i+1, len(runs)))
+			break
+		}
+	}
+	return append(lines, "(Enter: close)")
+}
@@ -0,0 +1,59 @@
+package main
+
+// defaultUndoDepth is how many moves Ctrl+Z / U can take back.
+const defaultUndoDepth = 10
+
+// boardSnapshot is the part of a board that a single move can change.
+type boardSnapshot struct {
+	cells       [][]cell
+	revealedCnt int
+	flagsCnt    int
+	placed      bool
+}
+
+func (b *board) snapshot() boardSnapshot {
+	cells := make([][]cell, len(b.cells))
+	for y := range b.cells {
+		cells[y] = append([]cell(nil), b.cells[y]...)
+	}
+	return boardSnapshot{cells: cells, revealedCnt: b.revealedCnt, flagsCnt: b.flagsCnt, placed: b.placed}
+}
+
+func (b *board) restore(s boardSnapshot) {
+	b.cells = s.cells
+	b.revealedCnt = s.revealedCnt
+	b.flagsCnt = s.flagsCnt
+	b.placed = s.placed
+}
+
+// pushUndo records s as the state to return to, dropping the oldest entry
+// once UndoDepth is reached. Practice mode keeps every entry.
+func (g *game) pushUndo(s boardSnapshot) {
+	if g.practiceMode {
+		g.undo = append(g.undo, s)
+		return
+	}
+	if g.UndoDepth <= 0 {
+		return
+	}
+	if len(g.undo) >= g.UndoDepth {
+		g.undo = append(g.undo[:0], g.undo[len(g.undo)-g.UndoDepth+1:]...)
+	}
+	g.undo = append(g.undo, s)
+}
+
+// undoMove takes back the last reveal or mark. Finished games stay finished.
+func (g *game) undoMove() bool {
+	if g.state != statePlaying || g.paused || len(g.undo) == 0 {
+		return false
+	}
+	last := len(g.undo) - 1
+	g.b.restore(g.undo[last])
+	g.undo = g.undo[:last]
+	g.practicePending = statePlaying
+	g.revealQueue = nil
+	g.fading = nil
+	g.clearHints()
+	g.events.Publish(MoveEvent{Action: ReplayUndo})
+	return true
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// The window never shrinks below what the top panel needs, or below two
+// rows of cells.
+const minViewW = 200
+
+// viewport is the part of a board too big for the window that is on
+// screen. offX and offY are the scroll position in pixels.
+type viewport struct {
+	// outsideW and outsideH are the window size from the last Layout call;
+	// zero until the window reports one.
+	outsideW, outsideH int
+	offX, offY         int
+
+	// a middle-button press pans when dragged and chords when it is not
+	dragging, dragged bool
+	dragFrom          point
+	dragOff           point
+}
+
+// Zoom scales the drawn board between minZoom and maxZoom in zoomStep
+// steps, on top of the cell size.
+const (
+	minZoom  = 0.5
+	maxZoom  = 3.0
+	zoomStep = 0.25
+)
+
+// zoom is the board's scale; the zero value of zoomLevel means 1.
+func (g *game) zoom() float64 {
+	if g.zoomLevel == 0 {
+		return 1
+	}
+	return g.zoomLevel
+}
+
+// cellSpan is the side of a cell on screen, zoom included.
+func (g *game) cellSpan() float64 {
+	return float64(cellSizePx) * g.zoom()
+}
+
+// setZoom changes the zoom, kept within minZoom and maxZoom, and sizes the
+// window for it.
+func (g *game) setZoom(z float64) {
+	g.zoomLevel = math.Max(minZoom, math.Min(maxZoom, z))
+	g.resizeWindow()
+	g.showToast(fmt.Sprintf("Zoom %.0f%%", g.zoomLevel*100))
+}
+
+// boardPixelSize is the size of the whole board on screen in pixels.
+func (g *game) boardPixelSize() (int, int) {
+	span := g.cellSpan()
+	return int(math.Ceil(float64(g.b.W) * span)), int(math.Ceil(float64(g.b.H) * span))
+}
+
+// viewSize is the window needed for the whole board, cut down to the
+// screen and to the window the player resized to.
+func (g *game) viewSize() (int, int) {
+	bw, bh := g.boardPixelSize()
+	fullW, fullH := bw+outerPadding*2, topPanelHeight+bh+outerPadding*2
+	w, h := fullW, fullH
+	if sw, sh := ebiten.ScreenSizeInFullscreen(); sw > 0 && sh > 0 {
+		w, h = min(w, sw), min(h, sh)
+	}
+	if g.view.outsideW > 0 && g.view.outsideH > 0 {
+		w, h = min(w, g.view.outsideW), min(h, g.view.outsideH)
+	}
+	w = max(w, min(fullW, minViewW))
+	h = max(h, min(fullH, topPanelHeight+2*int(g.cellSpan())+outerPadding*2))
+	return w, h
+}
+
+// boardRect is the on-screen area the board is drawn in.
+func (g *game) boardRect() image.Rectangle {
+	w, h := g.viewSize()
+	bw, bh := g.boardPixelSize()
+	vw := min(bw, w-outerPadding*2)
+	vh := min(bh, h-topPanelHeight-outerPadding*2)
+	return image.Rect(outerPadding, topPanelHeight, outerPadding+vw, topPanelHeight+vh)
+}
+
+// cellOrigin is the top-left corner of cell (x,y) on screen, moved by the
+// shake while the board is being drawn. While a zoomed board is drawn it
+// is the corner in the unscaled board image instead.
+func (g *game) cellOrigin(x, y int) (int, int) {
+	if g.zoomDrawing {
+		return x*cellSizePx - g.zoomOff.X + g.shake.X, y*cellSizePx - g.zoomOff.Y + g.shake.Y
+	}
+	span := g.cellSpan()
+	return outerPadding + int(float64(x)*span) - g.view.offX + g.shake.X, topPanelHeight + int(float64(y)*span) - g.view.offY + g.shake.Y
+}
+
+// zoomTarget is what the board is drawn on this frame: the board area of
+// screen itself, or at any zoom but 1 an unscaled image that finishZoom
+// then scales into it.
+func (g *game) zoomTarget(boardImg *ebiten.Image) *ebiten.Image {
+	z := g.zoom()
+	if z == 1 {
+		return boardImg
+	}
+	r := boardImg.Bounds()
+	w, h := int(math.Ceil(float64(r.Dx())/z)), int(math.Ceil(float64(r.Dy())/z))
+	if g.zoomImg == nil || g.zoomImg.Bounds().Dx() != w || g.zoomImg.Bounds().Dy() != h {
+		g.zoomImg = ebiten.NewImage(w, h)
+	}
+	g.zoomImg.Clear()
+	g.zoomOff = point{X: int(float64(g.view.offX) / z), Y: int(float64(g.view.offY) / z)}
+	g.zoomDrawing = true
+	return g.zoomImg
+}
+
+// finishZoom scales the zoomed board image onto the board area. Nearest
+// filtering keeps fractional zooms crisp rather than blurred.
+func (g *game) finishZoom(boardImg *ebiten.Image) {
+	if !g.zoomDrawing {
+		return
+	}
+	g.zoomDrawing = false
+	r := boardImg.Bounds()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(g.zoom(), g.zoom())
+	op.GeoM.Translate(float64(r.Min.X), float64(r.Min.Y))
+	op.Filter = ebiten.FilterNearest
+	boardImg.DrawImage(g.zoomImg, op)
+}
+
+// visibleCells is the range of columns and rows at least partly on screen,
+// end exclusive.
+func (g *game) visibleCells() (x0, y0, x1, y1 int) {
+	r := g.boardRect()
+	span := g.cellSpan()
+	x0, y0 = int(float64(g.view.offX)/span), int(float64(g.view.offY)/span)
+	x1 = min(g.b.W, int(math.Ceil(float64(g.view.offX+r.Dx())/span)))
+	y1 = min(g.b.H, int(math.Ceil(float64(g.view.offY+r.Dy())/span)))
+	return x0, y0, x1, y1
+}
+
+// clampView keeps the scroll position inside the board.
+func (g *game) clampView() {
+	r := g.boardRect()
+	bw, bh := g.boardPixelSize()
+	g.view.offX = clamp(g.view.offX, 0, bw-r.Dx())
+	g.view.offY = clamp(g.view.offY, 0, bh-r.Dy())
+}
+
+// scrollToCell scrolls just enough to bring cell (x,y) fully on screen.
+func (g *game) scrollToCell(x, y int) {
+	r := g.boardRect()
+	span := g.cellSpan()
+	px, py, cs := int(float64(x)*span), int(float64(y)*span), int(math.Ceil(span))
+	g.view.offX = clamp(g.view.offX, px+cs-r.Dx(), px)
+	g.view.offY = clamp(g.view.offY, py+cs-r.Dy(), py)
+	g.clampView()
+}
+
+// handleScrollKeys scrolls the view a cell at a time with Shift+arrows.
+func (g *game) handleScrollKeys() {
+	if shiftKeyRepeated(ebiten.KeyLeft) {
+		g.view.offX -= cellSizePx
+	}
+	if shiftKeyRepeated(ebiten.KeyRight) {
+		g.view.offX += cellSizePx
+	}
+	if shiftKeyRepeated(ebiten.KeyUp) {
+		g.view.offY -= cellSizePx
+	}
+	if shiftKeyRepeated(ebiten.KeyDown) {
+		g.view.offY += cellSizePx
+	}
+	g.clampView()
+}
+
+// handleMiddleMouse pans the board while the middle button is dragged. A
+// press that does not move is a chord, made when the button is released.
+func (g *game) handleMiddleMouse(mx, my int) {
+	mx, my = g.normalizeInputPos(mx, my)
+	v := &g.view
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) {
+		v.dragging, v.dragged = true, false
+		v.dragFrom = point{X: mx, Y: my}
+		v.dragOff = point{X: v.offX, Y: v.offY}
+	}
+	if !v.dragging {
+		return
+	}
+	dx, dy := mx-v.dragFrom.X, my-v.dragFrom.Y
+	if absInt(dx) > touchMoveSlopPx || absInt(dy) > touchMoveSlopPx {
+		v.dragged = true
+	}
+	if v.dragged {
+		v.offX, v.offY = v.dragOff.X-dx, v.dragOff.Y-dy
+		g.clampView()
+	}
+	// checking the button state rather than the release event also ends a
+	// drag whose release fell on a frame that skipped input
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) {
+		v.dragging = false
+		if !v.dragged {
+			g.handleChordAt(v.dragFrom.X, v.dragFrom.Y)
+		}
+	}
+}
+
+// drawScrollbars marks the visible part of a board that does not fit, in
+// the padding below and to the right of it.
+func (g *game) drawScrollbars(screen *ebiten.Image, th theme) {
+	r := g.boardRect()
+	bw, bh := g.boardPixelSize()
+	if bw > r.Dx() {
+		x := r.Min.X + g.view.offX*r.Dx()/bw
+		ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Max.Y+4), float64(r.Dx()), 4, th.Dark)
+		ebitenutil.DrawRect(screen, float64(x), float64(r.Max.Y+4), float64(r.Dx()*r.Dx()/bw), 4, th.Accent)
+	}
+	if bh > r.Dy() {
+		y := r.Min.Y + g.view.offY*r.Dy()/bh
+		ebitenutil.DrawRect(screen, float64(r.Max.X+4), float64(r.Min.Y), 4, float64(r.Dy()), th.Dark)
+		ebitenutil.DrawRect(screen, float64(r.Max.X+4), float64(y), 4, float64(r.Dy()*r.Dy()/bh), th.Accent)
+	}
+}
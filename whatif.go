@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// toggleWhatIf starts what-if mode on the game in progress, or leaves it
+// and puts the board back the way it was.
+func (g *game) toggleWhatIf() {
+	if g.whatIf {
+		g.closeWhatIf()
+		return
+	}
+	if !g.b.placed || g.state != statePlaying || len(g.revealQueue) > 0 {
+		g.showToast("What-if needs a game in progress")
+		return
+	}
+	g.whatIf = true
+	g.whatIfSnap = g.b.snapshot()
+	g.whatIfCells = map[[2]int]bool{}
+	g.showHelp = false
+	g.showScores = false
+	g.clearHints()
+}
+
+// closeWhatIf rolls back every what-if reveal.
+func (g *game) closeWhatIf() {
+	g.b.restore(g.whatIfSnap)
+	g.whatIf = false
+	g.whatIfSnap = boardSnapshot{}
+	g.whatIfCells = nil
+}
+
+// handleWhatIf runs what-if mode: a click opens a safe cell, with its
+// cascade, as a trial that Esc or Ctrl+W takes back. Nothing done here is
+// a move: it is not recorded, and it can neither win nor lose the game.
+func (g *game) handleWhatIf() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || ctrlKeyJustPressed(ebiten.KeyW) {
+		g.closeWhatIf()
+		return
+	}
+	g.handleScrollKeys()
+	primary, _ := g.mouseButtons()
+	if !inpututil.IsMouseButtonJustPressed(primary) {
+		return
+	}
+	x, y, ok := g.boardPosFromCursor(g.normalizeInputPos(ebiten.CursorPosition()))
+	if !ok {
+		return
+	}
+	c := g.b.cells[y][x]
+	if c.Revealed() || c.Flagged() {
+		return
+	}
+	// this is a thinking aid, so it may look at the layout to refuse a
+	// mine rather than play it
+	if c.Mine {
+		g.showToast("That cell is a mine")
+		return
+	}
+	_, cells := g.b.planReveal(x, y)
+	for _, p := range cells {
+		if g.b.revealOne(p[0], p[1]) {
+			g.whatIfCells[p] = true
+		}
+	}
+}
+
+func (g *game) whatIfStatus() []string {
+	return []string{
+		"[WHAT-IF]  click: try opening a safe cell",
+		"Esc / Ctrl+W: roll back",
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// windowState is the window's position and size, kept in window.json so
+// the next launch opens where the last one closed.
+type windowState struct {
+	X, Y, W, H int
+}
+
+func windowFilePath() string {
+	return configFilePath("window.json")
+}
+
+func loadWindowState() (windowState, bool) {
+	data, err := os.ReadFile(windowFilePath())
+	if err != nil {
+		return windowState{}, false
+	}
+	var ws windowState
+	if err := json.Unmarshal(data, &ws); err != nil || ws.W <= 0 || ws.H <= 0 {
+		return windowState{}, false
+	}
+	return ws, true
+}
+
+func saveWindowState(ws windowState) error {
+	data, err := json.MarshalIndent(ws, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(windowFilePath(), data, 0o644)
+}
+
+// restoreWindow applies the saved window state before the first frame. The
+// position is skipped when it would put the window off the screen.
+func restoreWindow() {
+	ws, ok := loadWindowState()
+	if !ok {
+		return
+	}
+	ebiten.SetWindowSize(ws.W, ws.H)
+	sw, sh := ebiten.ScreenSizeInFullscreen()
+	if ws.X >= 0 && ws.Y >= 0 && ws.X < sw && ws.Y < sh {
+		ebiten.SetWindowPosition(ws.X, ws.Y)
+	}
+}
+
+// captureWindow remembers the window for Shutdown to save. It runs on the
+// last frame, while the window can still be queried; a fullscreen window
+// leaves the saved state alone.
+func (g *game) captureWindow() {
+	if g.fullscreen {
+		return
+	}
+	x, y := ebiten.WindowPosition()
+	w, h := ebiten.WindowSize()
+	g.exitWindow = &windowState{X: x, Y: y, W: w, H: h}
+}